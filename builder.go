@@ -1,8 +1,14 @@
 package database
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/enorith/supports/str"
 )
 
 var (
@@ -14,37 +20,117 @@ var (
 	whereIn      = "i"
 	whereBetween = "t"
 	whereColumn  = "c"
+	whereRaw     = "r"
+	whereExists  = "e"
+	whereDate    = "d"
+)
+
+var (
+	lockUpdate = "update"
+	lockShare  = "share"
 )
 
 var DefaultPerPage = 15
 
+var (
+	ErrTableNotSet     = errors.New("query table is not set")
+	ErrUnsupported     = errors.New("statement is not supported by the grammar")
+	ErrNoColumns       = errors.New("rows to insert have no columns")
+	ErrEmptyIdentifier = errors.New("identifier of query is empty")
+)
+
 type QueryHandler func(builder *QueryBuilder)
 type JoinHandler func(clause *JoinClause)
+type ChunkHandler func(collection *Collection) error
 
 type QueryBuilder struct {
 	connection *Connection
-	columns    []string
-	from       string
+	ctx        context.Context
+	// columns and groups are strings or expressions
+	columns []interface{}
+	from    string
+	fromRaw *Expression
 
 	wheres   [][5]string
 	bindings []interface{}
 	// Do not use map
-	orders [][2]string
-	groups []string
-	limit  int
-	offset int
-	inLens []int
-	joins  []*JoinClause
+	orders []order
+	groups []interface{}
+	// havings share the structure of wheres
+	havings        [][5]string
+	havingBindings []interface{}
+	limit          int
+	offset         int
+	inLens         []int
+	joins          []*JoinClause
+	ctes           []cte
+	unions         []union
+	// orders and limits after unions
+	unionOrders []order
+	unionLimit  int
+	unionOffset int
+	// lock of selected rows, should be used in transaction
+	lock         string
+	lockModifier string
+	// immutable builder derives a new builder by each chained call
+	immutable bool
 }
 
-func (q *QueryBuilder) Where(column, operator string, value interface{}, and bool) *QueryBuilder {
-	q.addWhere(whereBasic, column, operator, and)
+type order struct {
+	column    interface{}
+	direction string
+}
+
+type union struct {
+	query *QueryBuilder
+	all   bool
+}
+
+// cte is common table expression, recursive is the recursive term
+// unioned to query
+type cte struct {
+	name      string
+	columns   []string
+	query     *QueryBuilder
+	recursive *QueryBuilder
+}
+
+func (q *QueryBuilder) Where(column interface{}, operator string, value interface{}, and bool) *QueryBuilder {
+	if e, ok := column.(Expression); ok {
+		return q.whereExpression(e, operator+" ?", and, value)
+	}
+	q = q.derive()
+	q.addWhere(whereBasic, columnName(column), operator, and)
 	q.bindings = append(q.bindings, value)
 
 	return q
 }
 
+func (q *QueryBuilder) WhereRaw(sql string, bindings ...interface{}) *QueryBuilder {
+	return q.whereExpression(Raw(sql, bindings...), "", true)
+}
+
+func (q *QueryBuilder) OrWhereRaw(sql string, bindings ...interface{}) *QueryBuilder {
+	return q.whereExpression(Raw(sql, bindings...), "", false)
+}
+
+// whereExpression add condition of expression column as raw where
+func (q *QueryBuilder) whereExpression(e Expression, condition string, and bool, bindings ...interface{}) *QueryBuilder {
+	q = q.derive()
+	q.addWhere(whereRaw, strings.TrimSpace(e.sql+" "+condition), "", and)
+	q.bindings = append(q.bindings, e.bindings...)
+	q.bindings = append(q.bindings, bindings...)
+
+	return q
+}
+
 func (q *QueryBuilder) addWhere(typ, column, operator string, and bool, others ...string) *QueryBuilder {
+	q.wheres = append(q.wheres, newCondition(typ, column, operator, and, others...))
+
+	return q
+}
+
+func newCondition(typ, column, operator string, and bool, others ...string) [5]string {
 	var b string
 	if and {
 		b = "and"
@@ -56,37 +142,37 @@ func (q *QueryBuilder) addWhere(typ, column, operator string, and bool, others .
 		other = others[0]
 	}
 
-	q.wheres = append(q.wheres, [5]string{column, typ, operator, b, other})
-
-	return q
+	return [5]string{column, typ, operator, b, other}
 }
 
-func (q *QueryBuilder) WhereNull(column string, and bool) *QueryBuilder {
+func (q *QueryBuilder) WhereNull(column interface{}, and bool) *QueryBuilder {
+	if e, ok := column.(Expression); ok {
+		return q.whereExpression(e, "is null", and)
+	}
 
-	q.addWhere(whereNull, column, "", and)
+	q = q.derive()
+	q.addWhere(whereNull, columnName(column), "", and)
 
 	return q
 }
 
-func (q *QueryBuilder) AndWhereNull(column string) *QueryBuilder {
-
-	q.WhereNull(column, true)
-
-	return q
+func (q *QueryBuilder) AndWhereNull(column interface{}) *QueryBuilder {
+	return q.WhereNull(column, true)
 }
 
-func (q *QueryBuilder) WhereNotNull(column string, and bool) *QueryBuilder {
+func (q *QueryBuilder) WhereNotNull(column interface{}, and bool) *QueryBuilder {
+	if e, ok := column.(Expression); ok {
+		return q.whereExpression(e, "is not null", and)
+	}
 
-	q.addWhere(whereNotNull, column, "", and)
+	q = q.derive()
+	q.addWhere(whereNotNull, columnName(column), "", and)
 
 	return q
 }
 
-func (q *QueryBuilder) AndWhereNotNull(column string) *QueryBuilder {
-
-	q.WhereNotNull(column, true)
-
-	return q
+func (q *QueryBuilder) AndWhereNotNull(column interface{}) *QueryBuilder {
+	return q.WhereNotNull(column, true)
 }
 
 func (q *QueryBuilder) WhereNest(and bool, handler QueryHandler) (*QueryBuilder, error) {
@@ -95,11 +181,12 @@ func (q *QueryBuilder) WhereNest(and bool, handler QueryHandler) (*QueryBuilder,
 
 	grammar, err := q.connection.GetGrammar()
 	if err != nil {
-		return builder, err
+		return q, err
 	}
 
 	sql := grammar.CompileWheres(builder, false)
 
+	q = q.derive()
 	q.bindings = append(q.bindings, builder.bindings...)
 
 	q.addWhere(whereNest, sql, "", and)
@@ -111,63 +198,347 @@ func (q *QueryBuilder) AndWhereNest(handler QueryHandler) (*QueryBuilder, error)
 	return q.WhereNest(true, handler)
 }
 
+// Exists returns false on any error, use ExistsOrError to check the error
 func (q *QueryBuilder) Exists() bool {
-	sql := q.connection.grammar.CompileExists(q)
-	rows, err := q.connection.Select(sql, q.FlatBindings()...)
+	exists, _ := q.ExistsOrError()
+
+	return exists
+}
+
+func (q *QueryBuilder) ExistsOrError() (bool, error) {
+	grammar, err := q.tableGrammar()
+	if err != nil {
+		return false, err
+	}
+	sql := grammar.CompileExists(q)
+	rows, err := q.connection.SelectContext(q.selectContext(), sql, q.FlatBindings()...)
 	if err != nil {
-		return false
+		return false, err
 	}
+	defer rows.Close()
+
 	var exists bool
-	rows.Next()
-	rows.Scan(&exists)
-	rows.Close()
+	if rows.Next() {
+		err = rows.Scan(&exists)
+	}
+	if err != nil {
+		return false, err
+	}
 
-	return exists
+	return exists, rows.Err()
 }
 
+// Count returns 0 on any error, use CountOrError to check the error
 func (q *QueryBuilder) Count(column ...string) int64 {
+	count, _ := q.CountOrError(column...)
 
-	grammar, e := q.connection.GetGrammar()
-	if e != nil {
-		return 0
+	return count
+}
+
+func (q *QueryBuilder) CountOrError(column ...string) (int64, error) {
+	var col string
+	if len(column) > 0 {
+		col = column[0]
+	}
+	v, err := q.Aggregate("count", col)
+	if err != nil {
+		return 0, err
+	}
+	if count, ok := v.(int64); ok {
+		return count, nil
+	}
+	count, err := aggregateFloat(v)
+
+	return int64(count), err
+}
+
+func (q *QueryBuilder) Sum(column string) (float64, error) {
+	v, err := q.Aggregate("sum", column)
+	if err != nil {
+		return 0, err
 	}
-	sql := grammar.CompileCount(q, column...)
 
-	rows, err := q.connection.Select(sql, q.FlatBindings()...)
+	return aggregateFloat(v)
+}
+
+func (q *QueryBuilder) Avg(column string) (float64, error) {
+	v, err := q.Aggregate("avg", column)
 	if err != nil {
-		return 0
+		return 0, err
 	}
-	var aggregate int64
-	rows.Next()
-	rows.Scan(&aggregate)
-	rows.Close()
 
-	return aggregate
+	return aggregateFloat(v)
+}
+
+func (q *QueryBuilder) Min(column string) (interface{}, error) {
+	return q.Aggregate("min", column)
 }
 
-func (q *QueryBuilder) WhereIn(column string, value []interface{}, and bool) *QueryBuilder {
-	q.addWhere(whereIn, column, "in", and)
+func (q *QueryBuilder) Max(column string) (interface{}, error) {
+	return q.Aggregate("max", column)
+}
+
+// Aggregate query aggregate function of column, the value is parsed by DefaultTypeParser,
+// nil for aggregating empty rows
+func (q *QueryBuilder) Aggregate(fn, column string) (interface{}, error) {
+	grammar, err := q.tableGrammar()
+	if err != nil {
+		return nil, err
+	}
+	sql := grammar.CompileAggregate(q, fn, column)
+	bindings := q.FlatBindings()
+	if len(q.unions) < 1 {
+		// selected columns are replaced by the aggregate
+		bindings = q.Clone().Select().FlatBindings()
+	}
+
+	coll, err := q.GetRaw(sql, bindings...)
+	if err != nil {
+		return nil, err
+	}
+	item := coll.First()
+	if !item.IsValid() {
+		return nil, nil
+	}
+
+	return item.GetValue("aggregate")
+}
+
+func aggregateFloat(v interface{}) (float64, error) {
+	switch value := v.(type) {
+	case nil:
+		return 0, nil
+	case int64:
+		return float64(value), nil
+	case uint64:
+		return float64(value), nil
+	case float64:
+		return value, nil
+	case string:
+		return strconv.ParseFloat(value, 64)
+	case []byte:
+		return strconv.ParseFloat(string(value), 64)
+	}
+
+	return 0, fmt.Errorf("aggregate: unexpected value type %T", v)
+}
+
+func (q *QueryBuilder) WhereIn(column interface{}, value []interface{}, and bool) *QueryBuilder {
+	return q.whereIn(column, "in", value, and)
+}
+
+func (q *QueryBuilder) whereIn(column interface{}, operator string, value []interface{}, and bool) *QueryBuilder {
+	if e, ok := column.(Expression); ok {
+		if len(value) < 1 {
+			return q.inEmpty(operator, and)
+		}
+		placeholder := strings.Join(str.Duplicate("?", len(value)), ",")
+		return q.whereExpression(e, fmt.Sprintf("%s (%s)", operator, placeholder), and, value...)
+	}
+	q = q.derive()
+	q.addWhere(whereIn, columnName(column), operator, and)
 	q.bindings = append(q.bindings, value)
 	q.inLens = append(q.inLens, len(value))
 
 	return q
 }
 
-func (q *QueryBuilder) WhereBetween(column string, one interface{}, two interface{}, and bool) *QueryBuilder {
-	q.addWhere(whereBetween, column, "between", and)
+// inEmpty add condition of empty "in", since "in ()" is a syntax error
+func (q *QueryBuilder) inEmpty(operator string, and bool) *QueryBuilder {
+	q = q.derive()
+	if operator == "in" {
+		return q.addWhere(whereRaw, "0 = 1", "", and)
+	}
+
+	return q.addWhere(whereRaw, "1 = 1", "", and)
+}
+
+func (q *QueryBuilder) WhereNotIn(column interface{}, value []interface{}, and bool) *QueryBuilder {
+	return q.whereIn(column, "not in", value, and)
+}
+
+func (q *QueryBuilder) AndWhereNotIn(column interface{}, value []interface{}) *QueryBuilder {
+	return q.WhereNotIn(column, value, true)
+}
+
+func (q *QueryBuilder) OrWhereNotIn(column interface{}, value []interface{}) *QueryBuilder {
+	return q.WhereNotIn(column, value, false)
+}
+
+func (q *QueryBuilder) WhereBetween(column interface{}, one interface{}, two interface{}, and bool) *QueryBuilder {
+	return q.whereBetween(column, "between", one, two, and)
+}
+
+func (q *QueryBuilder) whereBetween(column interface{}, operator string, one interface{}, two interface{}, and bool) *QueryBuilder {
+	if e, ok := column.(Expression); ok {
+		return q.whereExpression(e, operator+" ? and ?", and, one, two)
+	}
+	q = q.derive()
+	q.addWhere(whereBetween, columnName(column), operator, and)
 	q.bindings = append(q.bindings, [2]interface{}{one, two})
 
 	return q
 }
 
+func (q *QueryBuilder) WhereNotBetween(column interface{}, one interface{}, two interface{}, and bool) *QueryBuilder {
+	return q.whereBetween(column, "not between", one, two, and)
+}
+
+func (q *QueryBuilder) AndWhereNotBetween(column interface{}, one interface{}, two interface{}) *QueryBuilder {
+	return q.WhereNotBetween(column, one, two, true)
+}
+
+func (q *QueryBuilder) OrWhereNotBetween(column interface{}, one interface{}, two interface{}) *QueryBuilder {
+	return q.WhereNotBetween(column, one, two, false)
+}
+
+// WhereColumn compare two columns
+func (q *QueryBuilder) WhereColumn(first, operator, second string, and bool) *QueryBuilder {
+	return q.derive().addWhere(whereColumn, first, operator, and, second)
+}
+
+func (q *QueryBuilder) AndWhereColumn(first, operator, second string) *QueryBuilder {
+	return q.WhereColumn(first, operator, second, true)
+}
+
+func (q *QueryBuilder) OrWhereColumn(first, operator, second string) *QueryBuilder {
+	return q.WhereColumn(first, operator, second, false)
+}
+
+// WhereDate compare date part of column, time.Time value is formatted as "2006-01-02"
+func (q *QueryBuilder) WhereDate(column, operator string, value interface{}, and bool) *QueryBuilder {
+	if t, ok := value.(time.Time); ok {
+		value = t.Format("2006-01-02")
+	}
+	return q.whereDate("date", column, operator, value, and)
+}
+
+func (q *QueryBuilder) AndWhereDate(column, operator string, value interface{}) *QueryBuilder {
+	return q.WhereDate(column, operator, value, true)
+}
+
+func (q *QueryBuilder) OrWhereDate(column, operator string, value interface{}) *QueryBuilder {
+	return q.WhereDate(column, operator, value, false)
+}
+
+// WhereTime compare time part of column, time.Time value is formatted as "15:04:05"
+func (q *QueryBuilder) WhereTime(column, operator string, value interface{}, and bool) *QueryBuilder {
+	if t, ok := value.(time.Time); ok {
+		value = t.Format("15:04:05")
+	}
+	return q.whereDate("time", column, operator, value, and)
+}
+
+func (q *QueryBuilder) AndWhereTime(column, operator string, value interface{}) *QueryBuilder {
+	return q.WhereTime(column, operator, value, true)
+}
+
+func (q *QueryBuilder) OrWhereTime(column, operator string, value interface{}) *QueryBuilder {
+	return q.WhereTime(column, operator, value, false)
+}
+
+func (q *QueryBuilder) WhereYear(column, operator string, value interface{}, and bool) *QueryBuilder {
+	return q.whereDate("year", column, operator, value, and)
+}
+
+func (q *QueryBuilder) AndWhereYear(column, operator string, value interface{}) *QueryBuilder {
+	return q.WhereYear(column, operator, value, true)
+}
+
+func (q *QueryBuilder) OrWhereYear(column, operator string, value interface{}) *QueryBuilder {
+	return q.WhereYear(column, operator, value, false)
+}
+
+func (q *QueryBuilder) WhereMonth(column, operator string, value interface{}, and bool) *QueryBuilder {
+	return q.whereDate("month", column, operator, value, and)
+}
+
+func (q *QueryBuilder) AndWhereMonth(column, operator string, value interface{}) *QueryBuilder {
+	return q.WhereMonth(column, operator, value, true)
+}
+
+func (q *QueryBuilder) OrWhereMonth(column, operator string, value interface{}) *QueryBuilder {
+	return q.WhereMonth(column, operator, value, false)
+}
+
+func (q *QueryBuilder) WhereDay(column, operator string, value interface{}, and bool) *QueryBuilder {
+	return q.whereDate("day", column, operator, value, and)
+}
+
+func (q *QueryBuilder) AndWhereDay(column, operator string, value interface{}) *QueryBuilder {
+	return q.WhereDay(column, operator, value, true)
+}
+
+func (q *QueryBuilder) OrWhereDay(column, operator string, value interface{}) *QueryBuilder {
+	return q.WhereDay(column, operator, value, false)
+}
+
+func (q *QueryBuilder) whereDate(part, column, operator string, value interface{}, and bool) *QueryBuilder {
+	q = q.derive()
+	q.addWhere(whereDate, column, operator, and, part)
+	q.bindings = append(q.bindings, value)
+
+	return q
+}
+
+// WhereExists add "exists" sub query built by handler, the handler should set table with From
+func (q *QueryBuilder) WhereExists(and bool, handler QueryHandler) (*QueryBuilder, error) {
+	return q.whereExists("exists", and, handler)
+}
+
+func (q *QueryBuilder) AndWhereExists(handler QueryHandler) (*QueryBuilder, error) {
+	return q.WhereExists(true, handler)
+}
+
+func (q *QueryBuilder) OrWhereExists(handler QueryHandler) (*QueryBuilder, error) {
+	return q.WhereExists(false, handler)
+}
+
+func (q *QueryBuilder) WhereNotExists(and bool, handler QueryHandler) (*QueryBuilder, error) {
+	return q.whereExists("not exists", and, handler)
+}
+
+func (q *QueryBuilder) AndWhereNotExists(handler QueryHandler) (*QueryBuilder, error) {
+	return q.WhereNotExists(true, handler)
+}
+
+func (q *QueryBuilder) OrWhereNotExists(handler QueryHandler) (*QueryBuilder, error) {
+	return q.WhereNotExists(false, handler)
+}
+
+func (q *QueryBuilder) whereExists(operator string, and bool, handler QueryHandler) (*QueryBuilder, error) {
+	builder := q.NewQuery()
+	handler(builder)
+
+	grammar, err := q.connection.GetGrammar()
+	if err != nil {
+		return q, err
+	}
+
+	sql := grammar.Compile(builder)
+
+	q = q.derive()
+	q.bindings = append(q.bindings, builder.FlatBindings()...)
+
+	q.addWhere(whereExists, sql, operator, and)
+
+	return q, nil
+}
+
 func (q *QueryBuilder) WhereSub(from, column, operator string, and bool, handler QueryHandler) *QueryBuilder {
 	builder := q.NewQuery()
 
 	handler(builder)
 	builder.From(from)
-	sql := q.connection.grammar.Compile(builder)
+	grammar, err := q.connection.GetGrammar()
+	if err != nil {
+		// the query fails compiling by the same error
+		return q
+	}
+	sql := grammar.Compile(builder)
 
-	q.bindings = append(q.bindings, builder.bindings...)
+	q = q.derive()
+	q.bindings = append(q.bindings, builder.FlatBindings()...)
 
 	q.addWhere(whereSub, column, fmt.Sprintf("%s (%s)", operator, sql), and)
 	return q
@@ -177,15 +548,16 @@ func (q *QueryBuilder) AndWhereSub(from, column, operator string, handler QueryH
 	return q.WhereSub(from, column, operator, true, handler)
 }
 
-func (q *QueryBuilder) AndWhere(column string, operator string, value interface{}) *QueryBuilder {
+func (q *QueryBuilder) AndWhere(column interface{}, operator string, value interface{}) *QueryBuilder {
 	return q.Where(column, operator, value, true)
 }
 
-func (q *QueryBuilder) OrWhere(column string, operator string, value interface{}) *QueryBuilder {
+func (q *QueryBuilder) OrWhere(column interface{}, operator string, value interface{}) *QueryBuilder {
 	return q.Where(column, operator, value, false)
 }
 
 func (q *QueryBuilder) From(table string) *QueryBuilder {
+	q = q.derive()
 
 	q.from = table
 	return q
@@ -193,37 +565,55 @@ func (q *QueryBuilder) From(table string) *QueryBuilder {
 
 func (q *QueryBuilder) FromSub(builder *QueryBuilder, as string) (*QueryBuilder, error) {
 
-	toSql, err := builder.ToSql()
+	toSql, err := builder.compile()
 
+	if err != nil {
+		return nil, err
+	}
+	grammar, err := q.connection.GetGrammar()
 	if err != nil {
 		return nil, err
 	}
 
 	bindings := builder.FlatBindings()
 
-	q.from = Raw(fmt.Sprintf("(%s) as %s", toSql, WrapValue(as)))
-	q.bindings = append(q.bindings, bindings...)
+	return q.FromRaw(fmt.Sprintf("(%s) as %s", toSql, grammar.WrapTable(as)), bindings...), nil
+}
 
-	return q, nil
+func (q *QueryBuilder) FromRaw(sql string, bindings ...interface{}) *QueryBuilder {
+	q = q.derive()
+	q.fromRaw = &Expression{sql, bindings}
+	return q
 }
 
+// GetRaw query and load all rows before the deadline of ctx, an error is returned
+// instead of partial rows if reading fails
 func (q *QueryBuilder) GetRaw(query string, bindings ...interface{}) (*Collection, error) {
 
-	rows, err := q.connection.Select(query, bindings...)
+	rows, err := q.connection.SelectContext(q.selectContext(), query, bindings...)
 
 	if err != nil {
 		return nil, err
 	}
+	coll, err := CollectRows(rows)
+	if err != nil {
+		return nil, err
+	}
+	coll.LoadAll()
+	if err = coll.Err(); err != nil {
+		return nil, err
+	}
 
-	return CollectRows(rows)
+	return coll, nil
 }
 
-func (q *QueryBuilder) Get(columns ...string) (*Collection, error) {
-	if len(q.columns) < 1 {
+func (q *QueryBuilder) Get(columns ...interface{}) (*Collection, error) {
+	if len(q.columns) < 1 && len(columns) > 0 {
+		q = q.derive()
 		q.columns = columns
 	}
 
-	sql, err := q.ToSql()
+	sql, err := q.compile()
 
 	if err != nil {
 		return nil, err
@@ -232,16 +622,20 @@ func (q *QueryBuilder) Get(columns ...string) (*Collection, error) {
 	return q.GetRaw(sql, q.FlatBindings()...)
 }
 
-func (q *QueryBuilder) GetRowsIterator(columns ...string) (*RowsIterator, error) {
-	if len(q.columns) < 1 {
+// GetRowsIterator query rows to be read one by one, the default timeout of connection
+// is not applied since reading may take long, use WithContext to set a deadline.
+// The iterator should be closed
+func (q *QueryBuilder) GetRowsIterator(columns ...interface{}) (*RowsIterator, error) {
+	if len(q.columns) < 1 && len(columns) > 0 {
+		q = q.derive()
 		q.columns = columns
 	}
-	sql, e := q.ToSql()
+	sql, e := q.compile()
 	if e != nil {
 		return nil, e
 	}
 
-	rows, err := q.connection.Select(sql, q.FlatBindings()...)
+	rows, err := q.connection.SelectContext(withoutTimeout(q.selectContext()), sql, q.FlatBindings()...)
 
 	if err != nil {
 		return nil, err
@@ -250,20 +644,93 @@ func (q *QueryBuilder) GetRowsIterator(columns ...string) (*RowsIterator, error)
 	return NewRowsIterator(rows)
 }
 
-func (q *QueryBuilder) Sort(by string, direction string) *QueryBuilder {
-	q.orders = append(q.orders, [2]string{by, direction})
+// Chunk query by offset paging and call handler with each page until the
+// handler returns error, sort the query to keep the paging stable
+func (q *QueryBuilder) Chunk(size int, handler ChunkHandler) error {
+	for page := 1; ; page++ {
+		coll, err := q.Clone().ForPage(page, size).Get()
+		if err != nil {
+			return err
+		}
+		count := coll.Len()
+		if count < 1 {
+			return nil
+		}
+		if err = handler(coll); err != nil {
+			return err
+		}
+		if count < size {
+			return nil
+		}
+	}
+}
+
+// ChunkByID query by keyset paging of column, it's safe to modify rows in the handler.
+// alias is the key of column in result, default to column without table.
+// Orders of the query are replaced by column ascending
+func (q *QueryBuilder) ChunkByID(size int, column string, handler ChunkHandler, alias ...string) error {
+	key := column
+	if len(alias) > 0 {
+		key = alias[0]
+	} else if i := strings.LastIndexByte(column, '.'); i > -1 {
+		key = column[i+1:]
+	}
+	var last interface{}
+
+	for {
+		builder := q.Clone()
+		builder.orders = []order{}
+		if last != nil {
+			builder = builder.AndWhere(column, ">", last)
+		}
+		coll, err := builder.SortAsc(column).Take(size).Get()
+		if err != nil {
+			return err
+		}
+		count := coll.Len()
+		if count < 1 {
+			return nil
+		}
+		if last, err = coll.GetItem(count - 1).GetValue(key); err != nil {
+			return err
+		}
+		if err = handler(coll); err != nil {
+			return err
+		}
+		if count < size {
+			return nil
+		}
+	}
+}
+
+// Lazy returns a lazy collection which fetches items page by page on demand
+func (q *QueryBuilder) Lazy(size int) *LazyCollection {
+	return &LazyCollection{builder: q, size: size}
+}
+
+func (q *QueryBuilder) Sort(by interface{}, direction string) *QueryBuilder {
+	q = q.derive()
+	if len(q.unions) > 0 {
+		q.unionOrders = append(q.unionOrders, order{by, direction})
+		return q
+	}
+	q.orders = append(q.orders, order{by, direction})
 
 	return q
 }
-func (q *QueryBuilder) SortDesc(by string) *QueryBuilder {
+func (q *QueryBuilder) SortDesc(by interface{}) *QueryBuilder {
 	return q.Sort(by, "desc")
 }
 
-func (q *QueryBuilder) SortAsc(by string) *QueryBuilder {
+func (q *QueryBuilder) SortAsc(by interface{}) *QueryBuilder {
 	return q.Sort(by, "asc")
 }
 
-func (q *QueryBuilder) First(columns ...string) (*CollectionItem, error) {
+func (q *QueryBuilder) OrderByRaw(sql string, bindings ...interface{}) *QueryBuilder {
+	return q.Sort(Raw(sql, bindings...), "")
+}
+
+func (q *QueryBuilder) First(columns ...interface{}) (*CollectionItem, error) {
 	coll, err := q.Take(1).Get(columns...)
 	if err != nil {
 		return &CollectionItem{}, err
@@ -272,10 +739,56 @@ func (q *QueryBuilder) First(columns ...string) (*CollectionItem, error) {
 	return coll.First(), nil
 }
 
+// FlatBindings returns bindings in order of the compiled select, bindings of from
+// subquery and joins come before wheres, whenever they are added
 func (q *QueryBuilder) FlatBindings() []interface{} {
 	var value []interface{}
+	for _, c := range q.ctes {
+		value = append(value, c.query.FlatBindings()...)
+		if c.recursive != nil {
+			value = append(value, c.recursive.FlatBindings()...)
+		}
+	}
+	value = append(value, expressionBindings(q.columns...)...)
+	if q.fromRaw != nil {
+		value = append(value, q.fromRaw.bindings...)
+	}
+	value = append(value, q.joinBindings()...)
+	value = append(value, flatBindings(q.bindings)...)
+	value = append(value, expressionBindings(q.groups...)...)
+	value = append(value, flatBindings(q.havingBindings)...)
+	value = append(value, orderBindings(q.orders)...)
+
+	for _, u := range q.unions {
+		value = append(value, u.query.FlatBindings()...)
+	}
+
+	return append(value, orderBindings(q.unionOrders)...)
+}
+
+func orderBindings(orders []order) []interface{} {
+	var value []interface{}
+	for _, o := range orders {
+		value = append(value, expressionBindings(o.column)...)
+	}
+
+	return value
+}
+
+func (q *QueryBuilder) joinBindings() []interface{} {
+	var value []interface{}
+
+	for _, join := range q.joins {
+		value = append(value, join.FlatBindings()...)
+	}
 
-	for _, v := range q.bindings {
+	return value
+}
+
+func flatBindings(bindings []interface{}) []interface{} {
+	var value []interface{}
+
+	for _, v := range bindings {
 		if inValue, ok := v.([]interface{}); ok {
 			value = append(value, inValue...)
 		} else if betweenValue, ok := v.([2]interface{}); ok {
@@ -289,23 +802,27 @@ func (q *QueryBuilder) FlatBindings() []interface{} {
 }
 
 func (q *QueryBuilder) Create(attributes map[string]interface{}, key ...string) (*CollectionItem, error) {
-	sql, bindings := q.connection.grammar.CompileInsertOne(q.from, attributes)
-
-	id, err := q.connection.InsertGetId(sql, bindings...)
+	grammar, err := q.tableGrammar()
 	if err != nil {
 		return &CollectionItem{}, err
 	}
-	var primary string
+	primary := "id"
+	if len(key) > 0 {
+		primary = key[0]
+	}
 
-	if id > 0 {
-		if len(key) > 0 {
-			primary = key[0]
-		} else {
-			primary = "id"
-		}
+	var id interface{}
+	if d, ok := grammar.(insertGetIdDialect); ok {
+		id, err = q.insertReturning(d.CompileInsertGetId(q.from, attributes, primary))
+	} else {
+		sql, bindings := grammar.CompileInsertOne(q.from, attributes)
+		id, err = q.connection.InsertGetIdContext(q.Context(), sql, bindings...)
+	}
+	if err != nil {
+		return &CollectionItem{}, err
 	}
 
-	found, findErr := NewBuilder(q.connection).From(q.from).AndWhere(primary, "=", id).First()
+	found, findErr := NewBuilder(q.connection).WithContext(q.Context()).From(q.from).AndWhere(primary, "=", id).First()
 	if findErr != nil {
 		return &CollectionItem{}, findErr
 	}
@@ -313,74 +830,351 @@ func (q *QueryBuilder) Create(attributes map[string]interface{}, key ...string)
 	return found, nil
 }
 
-func (q *QueryBuilder) Select(columns ...string) *QueryBuilder {
+// insertReturning execute insert returning the inserted key
+func (q *QueryBuilder) insertReturning(sql string, bindings []interface{}) (interface{}, error) {
+	rows, err := q.connection.SelectContext(UseWritePool(q.Context()), sql, bindings...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	q.connection.recordWrite(q.Context())
+
+	var id interface{}
+	if rows.Next() {
+		err = rows.Scan(&id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return id, rows.Err()
+}
+
+func (q *QueryBuilder) Update(values map[string]interface{}) (int64, error) {
+	grammar, err := q.tableGrammar()
+	if err != nil {
+		return 0, err
+	}
+	sql, bindings := grammar.CompileUpdate(q, values)
+
+	return q.affectingStatement(sql, bindings...)
+}
+
+func (q *QueryBuilder) Increment(column string, amount interface{}, extra map[string]interface{}) (int64, error) {
+	return q.incrementBy(column, "+", amount, extra)
+}
+
+func (q *QueryBuilder) Decrement(column string, amount interface{}, extra map[string]interface{}) (int64, error) {
+	return q.incrementBy(column, "-", amount, extra)
+}
+
+func (q *QueryBuilder) incrementBy(column, operator string, amount interface{}, extra map[string]interface{}) (int64, error) {
+	grammar, err := q.connection.GetGrammar()
+	if err != nil {
+		return 0, err
+	}
+	values := map[string]interface{}{
+		column: Raw(fmt.Sprintf("%s %s ?", grammar.Wrap(column), operator), amount),
+	}
+	for k, v := range extra {
+		values[k] = v
+	}
+
+	return q.Update(values)
+}
+
+func (q *QueryBuilder) Delete() (int64, error) {
+	grammar, err := q.tableGrammar()
+	if err != nil {
+		return 0, err
+	}
+	sql, bindings := grammar.CompileDelete(q)
+
+	return q.affectingStatement(sql, bindings...)
+}
+
+func (q *QueryBuilder) affectingStatement(sql string, bindings ...interface{}) (int64, error) {
+	result, err := q.connection.ExecContext(q.Context(), sql, bindings...)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// Insert rows, split into chunks to keep bindings under grammar's placeholder limit
+func (q *QueryBuilder) Insert(rows []map[string]interface{}) (int64, error) {
+	grammar, err := q.tableGrammar()
+	if err != nil {
+		return 0, err
+	}
+
+	return q.insertChunks(grammar, rows, func(chunk []map[string]interface{}) (string, []interface{}) {
+		return grammar.CompileInsertMany(q.from, chunk)
+	})
+}
+
+// Replace insert rows, existing rows with the same unique keys are deleted before inserting
+func (q *QueryBuilder) Replace(rows []map[string]interface{}) (int64, error) {
+	grammar, err := q.tableGrammar()
+	if err != nil {
+		return 0, err
+	}
+	d, ok := grammar.(replaceDialect)
+	if !ok {
+		return 0, ErrUnsupported
+	}
+
+	return q.insertChunks(grammar, rows, func(chunk []map[string]interface{}) (string, []interface{}) {
+		return d.CompileReplace(q.from, chunk)
+	})
+}
+
+func (q *QueryBuilder) InsertOrIgnore(rows []map[string]interface{}) (int64, error) {
+	grammar, err := q.tableGrammar()
+	if err != nil {
+		return 0, err
+	}
+
+	return q.insertChunks(grammar, rows, func(chunk []map[string]interface{}) (string, []interface{}) {
+		return grammar.CompileInsertOrIgnore(q.from, chunk)
+	})
+}
+
+// Upsert insert rows or update the given columns when uniqueBy columns conflict,
+// all inserting columns except uniqueBy will be updated if update is empty.
+// Rows are inserted ignoring conflicts if there is nothing to update, uniqueBy
+// is required by grammars except mysql
+func (q *QueryBuilder) Upsert(rows []map[string]interface{}, uniqueBy []string, update []string) (int64, error) {
+	grammar, err := q.tableGrammar()
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := q.insertChunks(grammar, rows, func(chunk []map[string]interface{}) (string, []interface{}) {
+		return grammar.CompileUpsert(q.from, chunk, uniqueBy, update)
+	})
+	if err == ErrUnsupported && len(uniqueBy) < 1 {
+		return affected, fmt.Errorf("upsert without uniqueBy: %w", err)
+	}
+
+	return affected, err
+}
+
+func (q *QueryBuilder) insertChunks(grammar Grammar, rows []map[string]interface{},
+	compile func(chunk []map[string]interface{}) (string, []interface{})) (int64, error) {
+	if len(rows) < 1 {
+		return 0, nil
+	}
+	columns := len(insertColumns(rows))
+	if columns < 1 {
+		return 0, ErrNoColumns
+	}
+	size := grammar.MaxPlaceholders() / columns
+	if d, ok := grammar.(insertRowsDialect); ok && size > d.maxInsertRows() {
+		size = d.maxInsertRows()
+	}
+	if size < 1 {
+		size = 1
+	}
+	var total int64
+
+	for start := 0; start < len(rows); start += size {
+		end := start + size
+		if end > len(rows) {
+			end = len(rows)
+		}
+		sql, bindings := compile(rows[start:end])
+		if sql == "" {
+			return total, ErrUnsupported
+		}
+		affected, err := q.affectingStatement(sql, bindings...)
+		if err != nil {
+			return total, err
+		}
+		total += affected
+	}
+
+	return total, nil
+}
+
+func (q *QueryBuilder) Select(columns ...interface{}) *QueryBuilder {
+	q = q.derive()
 	q.columns = columns
 	return q
 }
 
+// SelectRaw add expression to selected columns
+func (q *QueryBuilder) SelectRaw(sql string, bindings ...interface{}) *QueryBuilder {
+	q = q.derive()
+	q.columns = append(q.columns, Raw(sql, bindings...))
+	return q
+}
+
 func (q *QueryBuilder) Join(table, first, operator, second, category string) *QueryBuilder {
-	q.JoinWith(category, table, func(clause *JoinClause) {
+	return q.JoinWith(category, table, func(clause *JoinClause) {
 		clause.On(first, operator, second, true)
 	})
-
-	return q
 }
 
 func (q *QueryBuilder) LeftJoin(table, first, operator, second string) *QueryBuilder {
-	q.JoinWith("left", table, func(clause *JoinClause) {
+	return q.JoinWith("left", table, func(clause *JoinClause) {
 		clause.On(first, operator, second, true)
 	})
-
-	return q
 }
 
 func (q *QueryBuilder) RightJoin(table, first, operator, second string) *QueryBuilder {
-	q.JoinWith("right", table, func(clause *JoinClause) {
+	return q.JoinWith("right", table, func(clause *JoinClause) {
 		clause.On(first, operator, second, true)
 	})
-
-	return q
 }
 
 func (q *QueryBuilder) InnerJoin(table, first, operator, second string) *QueryBuilder {
-	q.JoinWith("inner", table, func(clause *JoinClause) {
+	return q.JoinWith("inner", table, func(clause *JoinClause) {
 		clause.On(first, operator, second, true)
 	})
-
-	return q
 }
 
 func (q *QueryBuilder) JoinWith(category, table string, handler JoinHandler) *QueryBuilder {
+	q = q.derive()
 	clause := &JoinClause{q.NewQuery(), category, table}
 	handler(clause)
 
 	q.joins = append(q.joins, clause)
-	q.bindings = append(q.bindings, clause.bindings...)
 
 	return q
 }
 
 func (q *QueryBuilder) Transaction(handler func(builder *QueryBuilder) error) error {
-	return q.connection.TransactionCall(func() error {
-		return handler(q.NewQuery())
+	return q.connection.TransactionContext(q.Context(), func(tx *Connection) error {
+		return handler(NewBuilder(tx).WithContext(q.Context()))
 	})
 }
 
 func (q *QueryBuilder) Take(limit int) *QueryBuilder {
+	q = q.derive()
+	if len(q.unions) > 0 {
+		q.unionLimit = limit
+		return q
+	}
 	q.limit = limit
 	return q
 }
 
 func (q *QueryBuilder) Offset(offset int) *QueryBuilder {
+	q = q.derive()
+	if len(q.unions) > 0 {
+		q.unionOffset = offset
+		return q
+	}
 	q.offset = offset
 	return q
 }
 
-func (q *QueryBuilder) GroupBy(columns ...string) *QueryBuilder {
+// LockForUpdate lock selected rows for update until the transaction ends
+func (q *QueryBuilder) LockForUpdate() *QueryBuilder {
+	q = q.derive()
+	q.lock = lockUpdate
+	return q
+}
+
+// SharedLock lock selected rows from updating until the transaction ends
+func (q *QueryBuilder) SharedLock() *QueryBuilder {
+	q = q.derive()
+	q.lock = lockShare
+	return q
+}
+
+// SkipLocked skip rows locked by others instead of waiting
+func (q *QueryBuilder) SkipLocked() *QueryBuilder {
+	q = q.derive()
+	q.lockModifier = "skip locked"
+	return q
+}
+
+// NoWait fail immediately if selecting rows locked by others
+func (q *QueryBuilder) NoWait() *QueryBuilder {
+	q = q.derive()
+	q.lockModifier = "nowait"
+	return q
+}
+
+// With add a common table expression named name
+func (q *QueryBuilder) With(name string, query *QueryBuilder, columns ...string) *QueryBuilder {
+	q = q.derive()
+	q.ctes = append(q.ctes, cte{name: name, columns: columns, query: query})
+	return q
+}
+
+// WithRecursive add a recursive common table expression, anchor is
+// unioned all with recursive which selects from name
+func (q *QueryBuilder) WithRecursive(name string, columns []string, anchor, recursive *QueryBuilder) *QueryBuilder {
+	q = q.derive()
+	q.ctes = append(q.ctes, cte{name: name, columns: columns, query: anchor, recursive: recursive})
+	return q
+}
+
+func (q *QueryBuilder) Union(other *QueryBuilder) *QueryBuilder {
+	q = q.derive()
+	q.unions = append(q.unions, union{other, false})
+	return q
+}
+
+func (q *QueryBuilder) UnionAll(other *QueryBuilder) *QueryBuilder {
+	q = q.derive()
+	q.unions = append(q.unions, union{other, true})
+	return q
+}
+
+func (q *QueryBuilder) GroupBy(columns ...interface{}) *QueryBuilder {
+	q = q.derive()
 	q.groups = append(q.groups, columns...)
 	return q
 }
 
+func (q *QueryBuilder) GroupByRaw(sql string, bindings ...interface{}) *QueryBuilder {
+	q = q.derive()
+	q.groups = append(q.groups, Raw(sql, bindings...))
+	return q
+}
+
+func (q *QueryBuilder) Having(column interface{}, operator string, value interface{}) *QueryBuilder {
+	return q.having(column, operator, true, value)
+}
+
+func (q *QueryBuilder) OrHaving(column interface{}, operator string, value interface{}) *QueryBuilder {
+	return q.having(column, operator, false, value)
+}
+
+func (q *QueryBuilder) having(column interface{}, operator string, and bool, value interface{}) *QueryBuilder {
+	if e, ok := column.(Expression); ok {
+		return q.addHaving(whereRaw, e.sql+" "+operator+" ?", "", and, append(e.bindings, value)...)
+	}
+
+	return q.addHaving(whereBasic, columnName(column), operator, and, value)
+}
+
+func (q *QueryBuilder) HavingRaw(sql string, bindings ...interface{}) *QueryBuilder {
+	return q.addHaving(whereRaw, sql, "", true, bindings...)
+}
+
+func (q *QueryBuilder) HavingBetween(column interface{}, one interface{}, two interface{}) *QueryBuilder {
+	if e, ok := column.(Expression); ok {
+		return q.addHaving(whereRaw, e.sql+" between ? and ?", "", true, append(e.bindings, one, two)...)
+	}
+
+	return q.addHaving(whereBetween, columnName(column), "between", true, [2]interface{}{one, two})
+}
+
+func (q *QueryBuilder) addHaving(typ, column, operator string, and bool, bindings ...interface{}) *QueryBuilder {
+	q = q.derive()
+	q.havings = append(q.havings, newCondition(typ, column, operator, and))
+	q.havingBindings = append(q.havingBindings, bindings...)
+
+	return q
+}
+
 func (q *QueryBuilder) ForPage(page, perPage int) *QueryBuilder {
 	return q.Offset((page - 1) * perPage).Take(perPage)
 }
@@ -390,9 +1184,17 @@ func (q *QueryBuilder) CountForPage(column ...string) int64 {
 	builder.limit = -1
 	builder.offset = -1
 	//builder.groups = []string{}
-	builder.orders = [][2]string{}
-	builder.Select(column...)
-	query := q.NewQuery()
+	builder.orders = []order{}
+	builder.unionLimit = -1
+	builder.unionOffset = -1
+	builder.unionOrders = []order{}
+	if len(column) > 0 {
+		builder.columns = make([]interface{}, len(column))
+		for i, c := range column {
+			builder.columns[i] = c
+		}
+	}
+	query := q.NewQuery().WithContext(q.Context())
 	sub, err := query.FromSub(builder, "page_count")
 	if err != nil {
 		return 0
@@ -419,8 +1221,48 @@ func (q *QueryBuilder) Paginate(page, perPage int) *LengthAwarePaginator {
 	}
 }
 
+func (q *QueryBuilder) SimplePaginate(page, perPage int) *SimplePaginator {
+	if page < 1 {
+		page = 1
+	}
+
+	if perPage < 1 {
+		perPage = DefaultPerPage
+	}
+
+	return &SimplePaginator{
+		builder: q,
+		page:    page,
+		perPage: perPage,
+	}
+}
+
+// CursorPaginate paginate by orders of query, cursor is next cursor of previous page,
+// empty for the first page
+func (q *QueryBuilder) CursorPaginate(perPage int, cursor string) *CursorPaginator {
+	if perPage < 1 {
+		perPage = DefaultPerPage
+	}
+
+	return &CursorPaginator{
+		builder: q,
+		perPage: perPage,
+		cursor:  cursor,
+	}
+}
+
+// ToSql returns sql with placeholders of the connection's dialect
 func (q *QueryBuilder) ToSql() (string, error) {
-	g, e := q.connection.GetGrammar()
+	sql, e := q.compile()
+	if e != nil {
+		return "", e
+	}
+
+	return q.connection.prepareSql(sql), nil
+}
+
+func (q *QueryBuilder) compile() (string, error) {
+	g, e := q.tableGrammar()
 
 	if e != nil {
 		return "", e
@@ -429,6 +1271,62 @@ func (q *QueryBuilder) ToSql() (string, error) {
 	return g.Compile(q), nil
 }
 
+// tableGrammar returns grammar of connection, the table of query should be set
+func (q *QueryBuilder) tableGrammar() (Grammar, error) {
+	if q.from == "" && q.fromRaw == nil {
+		return nil, ErrTableNotSet
+	}
+	if q.hasEmptyIdentifier() {
+		return nil, ErrEmptyIdentifier
+	}
+
+	return q.connection.GetGrammar()
+}
+
+// hasEmptyIdentifier checks columns of query and its joins, they compile to invalid sql if empty
+func (q *QueryBuilder) hasEmptyIdentifier() bool {
+	for _, c := range q.columns {
+		if c == "" {
+			return true
+		}
+	}
+	for _, g := range q.groups {
+		if g == "" {
+			return true
+		}
+	}
+	for _, o := range q.orders {
+		if o.column == "" {
+			return true
+		}
+	}
+	for _, j := range q.joins {
+		if j.table == "" || j.hasEmptyIdentifier() {
+			return true
+		}
+	}
+
+	return emptyConditionColumn(q.wheres) || emptyConditionColumn(q.havings)
+}
+
+func emptyConditionColumn(conditions [][5]string) bool {
+	for _, c := range conditions {
+		switch c[1] {
+		case whereRaw, whereNest, whereExists:
+			continue
+		case whereColumn:
+			if c[4] == "" {
+				return true
+			}
+		}
+		if c[0] == "" {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (q *QueryBuilder) Using(connection string) error {
 	var e error
 	q.connection, e = DefaultManager.GetConnection(connection)
@@ -462,7 +1360,31 @@ func (q *QueryBuilder) Remember(key string, d time.Duration) (*Collection, error
 	return q.Get()
 }
 
-func (q *QueryBuilder) GetColumns() []string {
+// WithContext set context of queries, queries will be canceled when ctx is done
+func (q *QueryBuilder) WithContext(ctx context.Context) *QueryBuilder {
+	q = q.derive()
+	q.ctx = ctx
+	return q
+}
+
+// selectContext returns context of selects, locking reads use the write pool
+func (q *QueryBuilder) selectContext() context.Context {
+	if q.lock != "" {
+		return UseWritePool(q.Context())
+	}
+
+	return q.Context()
+}
+
+func (q *QueryBuilder) Context() context.Context {
+	if q.ctx == nil {
+		return context.Background()
+	}
+
+	return q.ctx
+}
+
+func (q *QueryBuilder) GetColumns() []interface{} {
 	return q.columns
 }
 
@@ -482,20 +1404,67 @@ func (q *QueryBuilder) NewQuery() *QueryBuilder {
 	return NewBuilder(q.connection.Clone())
 }
 
+// Clone returns a deep copy of query, modifying the cloned one never changes the original
 func (q *QueryBuilder) Clone() *QueryBuilder {
-	return &QueryBuilder{
-		connection: q.connection,
-		columns:    q.columns,
-		from:       q.from,
-		wheres:     q.wheres,
-		bindings:   q.bindings,
-		orders:     q.orders,
-		groups:     q.groups,
-		limit:      q.limit,
-		offset:     q.offset,
-		inLens:     q.inLens,
-		joins:      q.joins,
+	cloned := &QueryBuilder{
+		connection:     q.connection,
+		ctx:            q.ctx,
+		columns:        append([]interface{}(nil), q.columns...),
+		from:           q.from,
+		wheres:         append([][5]string(nil), q.wheres...),
+		bindings:       append([]interface{}{}, q.bindings...),
+		fromRaw:        q.fromRaw,
+		orders:         append([]order{}, q.orders...),
+		groups:         append([]interface{}(nil), q.groups...),
+		havings:        append([][5]string(nil), q.havings...),
+		havingBindings: append([]interface{}(nil), q.havingBindings...),
+		limit:          q.limit,
+		offset:         q.offset,
+		inLens:         append([]int(nil), q.inLens...),
+		unionOrders:    append([]order(nil), q.unionOrders...),
+		unionLimit:     q.unionLimit,
+		unionOffset:    q.unionOffset,
+		lock:           q.lock,
+		lockModifier:   q.lockModifier,
+		immutable:      q.immutable,
+	}
+	for _, j := range q.joins {
+		cloned.joins = append(cloned.joins, &JoinClause{j.QueryBuilder.Clone(), j.category, j.table})
 	}
+	for _, c := range q.ctes {
+		c.query = c.query.Clone()
+		if c.recursive != nil {
+			c.recursive = c.recursive.Clone()
+		}
+		cloned.ctes = append(cloned.ctes, c)
+	}
+	for _, u := range q.unions {
+		cloned.unions = append(cloned.unions, union{u.query.Clone(), u.all})
+	}
+
+	return cloned
+}
+
+// Immutable returns a copy of query in immutable mode, each chained call of
+// it returns a new builder, so it can be shared as a base query safely
+func (q *QueryBuilder) Immutable() *QueryBuilder {
+	cloned := q.Clone()
+	cloned.immutable = true
+
+	return cloned
+}
+
+func (q *QueryBuilder) IsImmutable() bool {
+	return q.immutable
+}
+
+// derive returns the builder to be modified by a chained call
+func (q *QueryBuilder) derive() *QueryBuilder {
+	if q.immutable {
+		return q.Clone()
+	}
+
+	return q
 }
 
 type JoinClause struct {
@@ -519,9 +1488,11 @@ func (j *JoinClause) OrOn(first, operator, second string) *JoinClause {
 func NewBuilder(c *Connection) *QueryBuilder {
 	q := new(QueryBuilder)
 	q.connection = c
-	q.orders = [][2]string{}
+	q.orders = []order{}
 	q.bindings = []interface{}{}
 	q.offset = -1
 	q.limit = -1
+	q.unionOffset = -1
+	q.unionLimit = -1
 	return q
 }
@@ -2,16 +2,21 @@ package database_test
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"github.com/enorith/database"
 	_ "github.com/go-sql-driver/mysql"
 	"io/ioutil"
-	"log"
+	"sync"
 	"testing"
+	"time"
 )
 
 var builder *database.QueryBuilder
 
 func TestQueryBuilder_BasicQuery(t *testing.T) {
+	requireMysql(t)
 	sql, e := builder.From("user").Where("id", "=", 1, true).Get()
 
 	if e != nil {
@@ -23,6 +28,7 @@ func TestQueryBuilder_BasicQuery(t *testing.T) {
 }
 
 func TestQueryBuilder_Create(t *testing.T) {
+	requireMysql(t)
 	item, e := builder.From("user").Create(map[string]interface{}{
 		"name":  "jack",
 		"email": "jack@gmail.com",
@@ -38,6 +44,7 @@ func TestQueryBuilder_Create(t *testing.T) {
 }
 
 func TestQueryBuilder_Transaction(t *testing.T) {
+	requireMysql(t)
 	e := builder.Transaction(func(builder *database.QueryBuilder) error {
 		_, e := builder.From("articles").Create(map[string]interface{}{
 			"title":   "none exists",
@@ -58,19 +65,479 @@ func TestQueryBuilder_Transaction(t *testing.T) {
 	if e != nil {
 		t.Logf("transaction failed %v", e)
 	}
+
+	b, _ := database.DefaultManager.NewBuilder()
+	if b.From("articles").AndWhere("title", "=", "none exists").Exists() {
+		t.Fatalf("failed transaction should be rolled back")
+	}
 }
 
-func init() {
-	m = database.DefaultManager
-	m.Register(database.DefaultConnection, func() (*database.Connection, error) {
-		return database.NewConnection("mysql", "root:root@(127.0.0.1:13306)/test"), nil
+func TestQueryBuilder_NestedTransaction(t *testing.T) {
+	requireMysql(t)
+	b, _ := database.DefaultManager.NewBuilder()
+	e := b.Transaction(func(builder *database.QueryBuilder) error {
+		_, e := builder.From("articles").Create(map[string]interface{}{
+			"title":   "outer",
+			"content": "should exists",
+		})
+		if e != nil {
+			return e
+		}
+
+		builder.NewQuery().Transaction(func(builder *database.QueryBuilder) error {
+			builder.From("articles").Create(map[string]interface{}{
+				"title":   "inner",
+				"content": "should not exists",
+			})
+			return errors.New("rollback to savepoint")
+		})
+
+		return nil
 	})
+	if e != nil {
+		t.Fatalf("transaction error %v", e)
+	}
 
-	builder, _ = m.NewBuilder()
-	c, _ := m.GetConnection()
+	b, _ = database.DefaultManager.NewBuilder()
+	if !b.From("articles").AndWhere("title", "=", "outer").Exists() {
+		t.Fatalf("outer transaction should be committed")
+	}
+	b, _ = database.DefaultManager.NewBuilder()
+	if b.From("articles").AndWhere("title", "=", "inner").Exists() {
+		t.Fatalf("inner transaction should be rolled back to savepoint")
+	}
+}
+
+func TestQueryBuilder_UpdateAndDelete(t *testing.T) {
+	requireMysql(t)
+	b, _ := database.DefaultManager.NewBuilder()
+	affected, e := b.From("user").AndWhere("name", "=", "tom").Update(map[string]interface{}{
+		"age": 30,
+	})
+	if e != nil {
+		t.Fatalf("update error %v", e)
+	}
+	if affected != 1 {
+		t.Fatalf("update affected %d rows, expect 1", affected)
+	}
+
+	b, _ = database.DefaultManager.NewBuilder()
+	_, e = b.From("user").AndWhere("name", "=", "tom").Increment("age", 2, map[string]interface{}{
+		"email": "tom@example.com",
+	})
+	if e != nil {
+		t.Fatalf("increment error %v", e)
+	}
+
+	b, _ = database.DefaultManager.NewBuilder()
+	item, _ := b.From("user").AndWhere("name", "=", "tom").First()
+	if age, _ := item.GetUint("age"); age != 32 {
+		t.Fatalf("incremented age is %d, expect 32", age)
+	}
+
+	b, _ = database.DefaultManager.NewBuilder()
+	affected, e = b.From("user").AndWhere("name", "=", "tony").Delete()
+	if e != nil {
+		t.Fatalf("delete error %v", e)
+	}
+	if affected != 1 {
+		t.Fatalf("delete affected %d rows, expect 1", affected)
+	}
+}
+
+func TestQueryBuilder_Insert(t *testing.T) {
+	requireMysql(t)
+	var rows []map[string]interface{}
+	for i := 0; i < 2000; i++ {
+		rows = append(rows, map[string]interface{}{
+			"title":   fmt.Sprintf("bulk %d", i),
+			"content": "bulk inserted",
+		})
+	}
+	b, _ := database.DefaultManager.NewBuilder()
+	affected, e := b.From("articles").Insert(rows)
+	if e != nil {
+		t.Fatalf("insert error %v", e)
+	}
+	if affected != 2000 {
+		t.Fatalf("insert affected %d rows, expect 2000", affected)
+	}
+}
+
+func TestQueryBuilder_Upsert(t *testing.T) {
+	requireMysql(t)
+	b, _ := database.DefaultManager.NewBuilder()
+	_, e := b.From("articles").Upsert([]map[string]interface{}{
+		{"id": 1, "title": "foo upserted", "content": "upserted"},
+	}, []string{"id"}, []string{"title"})
+	if e != nil {
+		t.Fatalf("upsert error %v", e)
+	}
+
+	b, _ = database.DefaultManager.NewBuilder()
+	item, _ := b.From("articles").AndWhere("id", "=", 1).First()
+	if title, _ := item.GetString("title"); title != "foo upserted" {
+		t.Fatalf("upserted title is %s", title)
+	}
+
+	b, _ = database.DefaultManager.NewBuilder()
+	affected, e := b.From("articles").InsertOrIgnore([]map[string]interface{}{
+		{"id": 2, "title": "ignored", "content": "ignored"},
+	})
+	if e != nil {
+		t.Fatalf("insert or ignore error %v", e)
+	}
+	if affected != 0 {
+		t.Fatalf("insert or ignore affected %d rows, expect 0", affected)
+	}
+}
+
+func TestQueryBuilder_Having(t *testing.T) {
+	requireMysql(t)
+	b, _ := database.DefaultManager.NewBuilder()
+	coll, e := b.From("articles").Select(database.Raw("count(id) as total"), "title").
+		GroupBy("title").Having("title", "=", "bar").HavingRaw("count(id) > ?", 0).Get()
+	if e != nil {
+		t.Fatalf("having query error %v", e)
+	}
+	if coll.Len() != 1 {
+		t.Fatalf("having query got %d groups, expect 1", coll.Len())
+	}
+}
+
+func TestQueryBuilder_Union(t *testing.T) {
+	requireMysql(t)
+	articles, _ := database.DefaultManager.NewBuilder()
+	articles.From("articles").Select("title as label").AndWhere("id", "=", 2)
+
+	b, _ := database.DefaultManager.NewBuilder()
+	coll, e := b.From("user").Select("name as label").AndWhere("name", "=", "tom").
+		UnionAll(articles).Get()
+	if e != nil {
+		t.Fatalf("union query error %v", e)
+	}
+	if coll.Len() != 2 {
+		t.Fatalf("union query got %d rows, expect 2", coll.Len())
+	}
+
+	b, _ = database.DefaultManager.NewBuilder()
+	coll, e = b.From("user").Select("name as label").AndWhere("name", "=", "tom").
+		UnionAll(articles).SortDesc("label").Take(1).Get()
+	if e != nil {
+		t.Fatalf("union query error %v", e)
+	}
+	if label, _ := coll.First().GetString("label"); coll.Len() != 1 || label != "tom" {
+		t.Fatalf("union query with limit got %v", coll.GetItems())
+	}
+}
+
+func TestQueryBuilder_Aggregate(t *testing.T) {
+	requireMysql(t)
+	b, _ := database.DefaultManager.NewBuilder()
+	sum, e := b.From("articles").WhereIn("id", []interface{}{1, 2}, true).Sum("id")
+	if e != nil {
+		t.Fatalf("sum error %v", e)
+	}
+	if sum != 3 {
+		t.Fatalf("sum of id is %v, expect 3", sum)
+	}
+
+	b, _ = database.DefaultManager.NewBuilder()
+	max, e := b.From("articles").WhereIn("id", []interface{}{1, 2}, true).Max("id")
+	if e != nil {
+		t.Fatalf("max error %v", e)
+	}
+	t.Logf("max id %v", max)
+
+	b, _ = database.DefaultManager.NewBuilder()
+	_, e = b.From("not_exists_table").CountOrError()
+	if e == nil {
+		t.Fatalf("count from not exists table should return error")
+	}
+}
+
+func TestQueryBuilder_WithContext(t *testing.T) {
+	requireMysql(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	b, _ := database.DefaultManager.NewBuilder()
+	_, e := b.WithContext(ctx).From("user").Get()
+	if e == nil {
+		t.Fatalf("query with canceled context should return error")
+	}
+
+	c := database.NewConnection("mysql", "root:root@(127.0.0.1:13306)/test").Timeout(100 * time.Millisecond)
+	_, e = c.Exec("select sleep(1)")
+	if e == nil {
+		t.Fatalf("query exceeded connection timeout should return error")
+	}
+}
+
+func TestQueryBuilder_Chunk(t *testing.T) {
+	requireMysql(t)
+	b, _ := database.DefaultManager.NewBuilder()
+	total := b.From("articles").Count()
+
+	var chunked, chunkedByID, chunkedSorted, lazy int64
+	b, _ = database.DefaultManager.NewBuilder()
+	e := b.From("articles").SortAsc("id").Chunk(100, func(collection *database.Collection) error {
+		chunked += int64(collection.Len())
+		return nil
+	})
+	if e != nil {
+		t.Fatalf("chunk error %v", e)
+	}
+
+	b, _ = database.DefaultManager.NewBuilder()
+	e = b.From("articles").ChunkByID(100, "articles.id", func(collection *database.Collection) error {
+		chunkedByID += int64(collection.Len())
+		return nil
+	})
+	if e != nil {
+		t.Fatalf("chunk by id error %v", e)
+	}
+
+	b, _ = database.DefaultManager.NewBuilder()
+	e = b.From("articles").SortDesc("title").ChunkByID(100, "id", func(collection *database.Collection) error {
+		chunkedSorted += int64(collection.Len())
+		return nil
+	})
+	if e != nil {
+		t.Fatalf("chunk by id of sorted query error %v", e)
+	}
+
+	b, _ = database.DefaultManager.NewBuilder()
+	items := b.From("articles").SortAsc("id").Lazy(100)
+	for items.Next() {
+		lazy++
+	}
+	if items.Err() != nil {
+		t.Fatalf("lazy error %v", items.Err())
+	}
+
+	if chunked != total || chunkedByID != total || chunkedSorted != total || lazy != total {
+		t.Fatalf("chunked %d, chunked by id %d, chunked by id of sorted %d, lazy %d, expect %d",
+			chunked, chunkedByID, chunkedSorted, lazy, total)
+	}
+}
+
+func TestQueryBuilder_SimplePaginate(t *testing.T) {
+	requireMysql(t)
+	b, _ := database.DefaultManager.NewBuilder()
+	result, e := b.From("articles").SortAsc("id").SimplePaginate(1, 1).ToResult()
+	if e != nil {
+		t.Fatalf("simple paginate error %v", e)
+	}
+	if result.Data.Len() != 1 || !result.Meta.HasMore {
+		t.Fatalf("simple paginate got %d items, has more %v", result.Data.Len(), result.Meta.HasMore)
+	}
+}
+
+func TestQueryBuilder_CursorPaginate(t *testing.T) {
+	requireMysql(t)
+	b, _ := database.DefaultManager.NewBuilder()
+	first := b.From("articles").SortDesc("title").SortAsc("id").CursorPaginate(2, "")
+	firstItems, e := first.Items()
+	if e != nil {
+		t.Fatalf("cursor paginate error %v", e)
+	}
+	cursor, _ := first.NextCursor()
+	if cursor == "" {
+		t.Fatalf("cursor paginate should have next cursor")
+	}
+
+	b, _ = database.DefaultManager.NewBuilder()
+	secondItems, e := b.From("articles").SortDesc("title").SortAsc("id").CursorPaginate(2, cursor).Items()
+	if e != nil {
+		t.Fatalf("cursor paginate error %v", e)
+	}
+	last, _ := firstItems.GetItem(1).GetUint("id")
+	next, _ := secondItems.First().GetUint("id")
+	if last == next {
+		t.Fatalf("cursor paginate returns overlapped pages")
+	}
+}
+
+func TestQueryBuilder_LockForUpdate(t *testing.T) {
+	requireMysql(t)
+	b, _ := database.DefaultManager.NewBuilder()
+	e := b.Transaction(func(builder *database.QueryBuilder) error {
+		item, e := builder.From("articles").AndWhere("id", "=", 1).LockForUpdate().First()
+		if e != nil {
+			return e
+		}
+		if !item.IsValid() {
+			return errors.New("locked row not found")
+		}
+
+		_, e = builder.NewQuery().From("articles").AndWhere("id", "=", 1).Update(map[string]interface{}{
+			"content": "updated in lock",
+		})
+		return e
+	})
+	if e != nil {
+		t.Fatalf("lock for update error %v", e)
+	}
+}
+
+func TestQueryBuilder_WithRecursive(t *testing.T) {
+	requireMysql(t)
+	anchor, _ := database.DefaultManager.NewBuilder()
+	anchor.From("articles").Select("id").AndWhere("id", "=", 1)
+	recursive, _ := database.DefaultManager.NewBuilder()
+	recursive.From("seq").Select(database.Raw("id + 1")).AndWhere("id", "<", 3)
+
+	b, _ := database.DefaultManager.NewBuilder()
+	coll, e := b.WithRecursive("seq", []string{"id"}, anchor, recursive).From("seq").Get()
+	if e != nil {
+		t.Fatalf("recursive cte error %v", e)
+	}
+	if coll.Len() != 3 {
+		t.Fatalf("recursive cte got %d rows, expect 3", coll.Len())
+	}
+}
+
+func TestQueryBuilder_WhereHelpers(t *testing.T) {
+	requireMysql(t)
+	b, _ := database.DefaultManager.NewBuilder()
+	count, e := b.From("articles").WhereIn("id", []interface{}{}, true).CountOrError()
+	if e != nil {
+		t.Fatalf("where in empty error %v", e)
+	}
+	if count != 0 {
+		t.Fatalf("where in empty got %d rows, expect 0", count)
+	}
+
+	b, _ = database.DefaultManager.NewBuilder()
+	count, e = b.From("articles").AndWhereNotIn("id", []interface{}{1, 2}).
+		AndWhereNotBetween("id", 3, 5).AndWhereColumn("id", "=", "id").CountOrError()
+	if e != nil {
+		t.Fatalf("where not in error %v", e)
+	}
+	t.Logf("where not in count %d", count)
+
+	b, _ = database.DefaultManager.NewBuilder()
+	b.From("user")
+	_, e = b.AndWhereExists(func(builder *database.QueryBuilder) {
+		builder.From("articles").AndWhereColumn("articles.title", "=", "user.name")
+	})
+	if e != nil {
+		t.Fatalf("where exists error %v", e)
+	}
+	if b.Exists() {
+		t.Fatalf("where exists should not match any user")
+	}
+}
+
+func TestQueryBuilder_Expression(t *testing.T) {
+	requireMysql(t)
+	b, _ := database.DefaultManager.NewBuilder()
+	item, e := b.From("articles").SelectRaw("id + ? as next", 1).
+		WhereRaw("id = ?", 1).OrderByRaw("field(id, ?)", 1).First()
+	if e != nil {
+		t.Fatalf("select raw error %v", e)
+	}
+	next, _ := item.GetInt("next")
+	if next != 2 {
+		t.Fatalf("select raw got %d, expect 2", next)
+	}
+
+	b, _ = database.DefaultManager.NewBuilder()
+	q := b.FromRaw("articles as a").SelectRaw("a.id + ? as next", 1).AndWhere(database.Raw("a.id + ?", 2), ">", 3).
+		GroupByRaw("a.id, ?", 4).OrderByRaw("field(a.id, ?)", 5)
+	sql, e := q.ToSql()
+	if e != nil {
+		t.Fatalf("expression error %v", e)
+	}
+	expect := "select a.id + ? as next from articles as a where a.id + ? > ? group by a.id, ? order by field(a.id, ?) "
+	if sql != expect {
+		t.Fatalf("expression got %s, expect %s", sql, expect)
+	}
+	if bindings := fmt.Sprint(q.FlatBindings()); bindings != "[1 2 3 4 5]" {
+		t.Fatalf("expression got bindings %s, expect [1 2 3 4 5]", bindings)
+	}
+}
+
+func TestQueryBuilder_TablePrefix(t *testing.T) {
+	conn := database.NewConnection("mysql", "root:root@(127.0.0.1:13306)/test").TablePrefix("p_")
+	sql, e := database.NewBuilder(conn).From("users as u").Select("u.id", "name as n").
+		AndWhere("u.age", ">", 1).ToSql()
+	if e != nil {
+		t.Fatalf("table prefix error %v", e)
+	}
+	expect := "select `p_u`.`id`, `name` as `n` from `p_users` as `p_u` where `p_u`.`age` > ? "
+	if sql != expect {
+		t.Fatalf("table prefix got %s, expect %s", sql, expect)
+	}
+
+	_, e = database.NewBuilder(conn).ToSql()
+	if !errors.Is(e, database.ErrTableNotSet) {
+		t.Fatalf("empty table got error %v, expect %v", e, database.ErrTableNotSet)
+	}
+}
+
+func TestQueryBuilder_Clone(t *testing.T) {
+	requireMysql(t)
+	b, _ := database.DefaultManager.NewBuilder()
+	b.From("articles").JoinWith("left", "user", func(clause *database.JoinClause) {
+		clause.On("user.id", "=", "articles.id", true)
+	}).AndWhere("articles.id", ">", 0).SortAsc("articles.id")
+	expect, _ := b.ToSql()
+
+	cloned := b.Clone()
+	cloned.AndWhere("title", "=", "foo").SortDesc("title")
+	if sql, _ := b.ToSql(); sql != expect {
+		t.Fatalf("clone changed original query to %s", sql)
+	}
+	if len(b.FlatBindings()) != 1 {
+		t.Fatalf("clone changed original bindings to %v", b.FlatBindings())
+	}
+
+	base := b.Immutable()
+	foo := base.AndWhere("title", "=", "foo")
+	bar := base.AndWhere("title", "=", "bar")
+	if sql, _ := base.ToSql(); sql != expect {
+		t.Fatalf("immutable query changed to %s", sql)
+	}
+	fooBindings, barBindings := foo.FlatBindings(), bar.FlatBindings()
+	if len(fooBindings) != 2 || fooBindings[1] != "foo" || barBindings[1] != "bar" {
+		t.Fatalf("immutable derived bindings %v, %v", fooBindings, barBindings)
+	}
+}
+
+var (
+	mysqlOnce    sync.Once
+	mysqlSkipped error
+	mysqlErr     error
+)
+
+// requireMysql migrate the test database once, the test is skipped if mysql is unreachable
+func requireMysql(t *testing.T) {
+	t.Helper()
+	mysqlOnce.Do(migrateMysql)
+	if mysqlSkipped != nil {
+		t.Skipf("mysql is unreachable: %v", mysqlSkipped)
+	}
+	if mysqlErr != nil {
+		t.Fatalf("migartion error %v", mysqlErr)
+	}
+}
+
+func migrateMysql() {
+	c, _ := database.DefaultManager.GetConnection()
+	db, e := c.GetDB()
+	if e == nil {
+		e = db.Ping()
+	}
+	if e != nil {
+		mysqlSkipped = e
+		return
+	}
 	sql, e := ioutil.ReadFile("./migration.sql")
 	if e != nil {
-		log.Fatalf("read migartion error %v", e)
+		mysqlErr = e
+		return
 	}
 
 	tokens := bytes.Split(sql, []byte(";"))
@@ -78,10 +545,148 @@ func init() {
 	for _, token := range tokens {
 		token = bytes.TrimSpace(token)
 		if len(token) > 0 {
-			_, err := c.Exec(string(token))
-			if err != nil {
-				log.Fatalf("migartion error %v", err)
+			if _, e = c.Exec(string(token)); e != nil {
+				mysqlErr = e
+				return
 			}
 		}
 	}
 }
+
+func init() {
+	m = database.DefaultManager
+	m.Register(database.DefaultConnection, func() (*database.Connection, error) {
+		return database.NewConnection("mysql", "root:root@(127.0.0.1:13306)/test"), nil
+	})
+
+	builder, _ = m.NewBuilder()
+}
+
+func TestQueryBuilder_ImmutableWrappers(t *testing.T) {
+	base := postgresBuilder().From("t").Immutable()
+	nest := func(b *database.QueryBuilder) { b.AndWhere("a", "=", 1) }
+	other := postgresBuilder().From("o")
+	wrappers := map[string]func(q *database.QueryBuilder) *database.QueryBuilder{
+		"AndWhere":           func(q *database.QueryBuilder) *database.QueryBuilder { return q.AndWhere("a", "=", 1) },
+		"OrWhere":            func(q *database.QueryBuilder) *database.QueryBuilder { return q.OrWhere("a", "=", 1) },
+		"WhereRaw":           func(q *database.QueryBuilder) *database.QueryBuilder { return q.WhereRaw("a = ?", 1) },
+		"OrWhereRaw":         func(q *database.QueryBuilder) *database.QueryBuilder { return q.OrWhereRaw("a = ?", 1) },
+		"AndWhereNull":       func(q *database.QueryBuilder) *database.QueryBuilder { return q.AndWhereNull("a") },
+		"AndWhereNotNull":    func(q *database.QueryBuilder) *database.QueryBuilder { return q.AndWhereNotNull("a") },
+		"WhereIn":            func(q *database.QueryBuilder) *database.QueryBuilder { return q.WhereIn("a", []interface{}{1}, true) },
+		"AndWhereNotIn":      func(q *database.QueryBuilder) *database.QueryBuilder { return q.AndWhereNotIn("a", []interface{}{1}) },
+		"OrWhereNotIn":       func(q *database.QueryBuilder) *database.QueryBuilder { return q.OrWhereNotIn("a", []interface{}{1}) },
+		"AndWhereNotBetween": func(q *database.QueryBuilder) *database.QueryBuilder { return q.AndWhereNotBetween("a", 1, 2) },
+		"OrWhereNotBetween":  func(q *database.QueryBuilder) *database.QueryBuilder { return q.OrWhereNotBetween("a", 1, 2) },
+		"AndWhereColumn":     func(q *database.QueryBuilder) *database.QueryBuilder { return q.AndWhereColumn("a", "=", "b") },
+		"OrWhereColumn":      func(q *database.QueryBuilder) *database.QueryBuilder { return q.OrWhereColumn("a", "=", "b") },
+		"AndWhereDate":       func(q *database.QueryBuilder) *database.QueryBuilder { return q.AndWhereDate("a", "=", "2020-01-01") },
+		"OrWhereDate":        func(q *database.QueryBuilder) *database.QueryBuilder { return q.OrWhereDate("a", "=", "2020-01-01") },
+		"AndWhereTime":       func(q *database.QueryBuilder) *database.QueryBuilder { return q.AndWhereTime("a", "=", "10:00:00") },
+		"OrWhereTime":        func(q *database.QueryBuilder) *database.QueryBuilder { return q.OrWhereTime("a", "=", "10:00:00") },
+		"AndWhereYear":       func(q *database.QueryBuilder) *database.QueryBuilder { return q.AndWhereYear("a", "=", 2020) },
+		"OrWhereYear":        func(q *database.QueryBuilder) *database.QueryBuilder { return q.OrWhereYear("a", "=", 2020) },
+		"AndWhereMonth":      func(q *database.QueryBuilder) *database.QueryBuilder { return q.AndWhereMonth("a", "=", 1) },
+		"OrWhereMonth":       func(q *database.QueryBuilder) *database.QueryBuilder { return q.OrWhereMonth("a", "=", 1) },
+		"AndWhereDay":        func(q *database.QueryBuilder) *database.QueryBuilder { return q.AndWhereDay("a", "=", 1) },
+		"OrWhereDay":         func(q *database.QueryBuilder) *database.QueryBuilder { return q.OrWhereDay("a", "=", 1) },
+		"AndWhereSub":        func(q *database.QueryBuilder) *database.QueryBuilder { return q.AndWhereSub("o", "a", "in", nest) },
+		"AndWhereNest": func(q *database.QueryBuilder) *database.QueryBuilder {
+			q, _ = q.AndWhereNest(nest)
+			return q
+		},
+		"AndWhereExists": func(q *database.QueryBuilder) *database.QueryBuilder {
+			q, _ = q.AndWhereExists(func(b *database.QueryBuilder) { b.From("o") })
+			return q
+		},
+		"OrWhereExists": func(q *database.QueryBuilder) *database.QueryBuilder {
+			q, _ = q.OrWhereExists(func(b *database.QueryBuilder) { b.From("o") })
+			return q
+		},
+		"AndWhereNotExists": func(q *database.QueryBuilder) *database.QueryBuilder {
+			q, _ = q.AndWhereNotExists(func(b *database.QueryBuilder) { b.From("o") })
+			return q
+		},
+		"OrWhereNotExists": func(q *database.QueryBuilder) *database.QueryBuilder {
+			q, _ = q.OrWhereNotExists(func(b *database.QueryBuilder) { b.From("o") })
+			return q
+		},
+		"Join": func(q *database.QueryBuilder) *database.QueryBuilder {
+			return q.Join("u", "u.id", "=", "t.uid", "left")
+		},
+		"LeftJoin":      func(q *database.QueryBuilder) *database.QueryBuilder { return q.LeftJoin("u", "u.id", "=", "t.uid") },
+		"RightJoin":     func(q *database.QueryBuilder) *database.QueryBuilder { return q.RightJoin("u", "u.id", "=", "t.uid") },
+		"InnerJoin":     func(q *database.QueryBuilder) *database.QueryBuilder { return q.InnerJoin("u", "u.id", "=", "t.uid") },
+		"Sort":          func(q *database.QueryBuilder) *database.QueryBuilder { return q.Sort("a", "desc") },
+		"SortAsc":       func(q *database.QueryBuilder) *database.QueryBuilder { return q.SortAsc("a") },
+		"SortDesc":      func(q *database.QueryBuilder) *database.QueryBuilder { return q.SortDesc("a") },
+		"OrderByRaw":    func(q *database.QueryBuilder) *database.QueryBuilder { return q.OrderByRaw("a desc") },
+		"Select":        func(q *database.QueryBuilder) *database.QueryBuilder { return q.Select("a") },
+		"SelectRaw":     func(q *database.QueryBuilder) *database.QueryBuilder { return q.SelectRaw("count(*)") },
+		"GroupBy":       func(q *database.QueryBuilder) *database.QueryBuilder { return q.GroupBy("a") },
+		"GroupByRaw":    func(q *database.QueryBuilder) *database.QueryBuilder { return q.GroupByRaw("a") },
+		"Having":        func(q *database.QueryBuilder) *database.QueryBuilder { return q.Having("a", ">", 1) },
+		"OrHaving":      func(q *database.QueryBuilder) *database.QueryBuilder { return q.OrHaving("a", ">", 1) },
+		"HavingRaw":     func(q *database.QueryBuilder) *database.QueryBuilder { return q.HavingRaw("a > 1") },
+		"HavingBetween": func(q *database.QueryBuilder) *database.QueryBuilder { return q.HavingBetween("a", 1, 2) },
+		"Take":          func(q *database.QueryBuilder) *database.QueryBuilder { return q.Take(1) },
+		"ForPage":       func(q *database.QueryBuilder) *database.QueryBuilder { return q.ForPage(2, 10) },
+		"LockForUpdate": func(q *database.QueryBuilder) *database.QueryBuilder { return q.LockForUpdate() },
+		"SharedLock":    func(q *database.QueryBuilder) *database.QueryBuilder { return q.SharedLock() },
+		"With":          func(q *database.QueryBuilder) *database.QueryBuilder { return q.With("c", other) },
+		"Union":         func(q *database.QueryBuilder) *database.QueryBuilder { return q.Union(other) },
+		"UnionAll":      func(q *database.QueryBuilder) *database.QueryBuilder { return q.UnionAll(other) },
+		"From":          func(q *database.QueryBuilder) *database.QueryBuilder { return q.From("u") },
+		"FromRaw":       func(q *database.QueryBuilder) *database.QueryBuilder { return q.FromRaw("u") },
+	}
+
+	expect, _ := base.ToSql()
+	for name, wrapper := range wrappers {
+		derived := wrapper(base)
+		if sql, _ := base.ToSql(); sql != expect {
+			t.Errorf("%s changed immutable base to %s", name, sql)
+		}
+		if sql, _ := derived.ToSql(); sql == expect {
+			t.Errorf("%s lost its clause on immutable builder: %s", name, sql)
+		}
+	}
+
+	locked := base.LockForUpdate()
+	expect, _ = locked.ToSql()
+	for name, modifier := range map[string]func() *database.QueryBuilder{"SkipLocked": locked.SkipLocked, "NoWait": locked.NoWait} {
+		if sql, _ := modifier().ToSql(); sql == expect {
+			t.Errorf("%s lost its modifier on immutable builder: %s", name, sql)
+		}
+	}
+
+	// offset is compiled only with limit
+	limited := base.Take(1)
+	expect, _ = limited.ToSql()
+	if sql, _ := limited.Offset(1).ToSql(); sql == expect {
+		t.Errorf("Offset lost its clause on immutable builder: %s", sql)
+	}
+}
+
+func TestQueryBuilder_InsertNoColumns(t *testing.T) {
+	_, e := postgresBuilder().From("users").Insert([]map[string]interface{}{{}})
+	if !errors.Is(e, database.ErrNoColumns) {
+		t.Fatalf("insert rows without columns got error %v, expect %v", e, database.ErrNoColumns)
+	}
+}
+
+func TestQueryBuilder_EmptyIdentifier(t *testing.T) {
+	queries := map[string]*database.QueryBuilder{
+		"where":  postgresBuilder().From("users").AndWhere("", "=", 1),
+		"column": postgresBuilder().From("users").Select("id", ""),
+		"order":  postgresBuilder().From("users").SortAsc(""),
+		"join":   postgresBuilder().From("users").Join("roles", "roles.id", "=", "", "left"),
+	}
+	for name, q := range queries {
+		if _, e := q.ToSql(); !errors.Is(e, database.ErrEmptyIdentifier) {
+			t.Errorf("empty identifier of %s got error %v, expect %v", name, e, database.ErrEmptyIdentifier)
+		}
+		if _, e := q.Get(); !errors.Is(e, database.ErrEmptyIdentifier) {
+			t.Errorf("get with empty identifier of %s got error %v, expect %v", name, e, database.ErrEmptyIdentifier)
+		}
+	}
+}
@@ -258,6 +258,15 @@ func (c *Collection) Close() error {
 	return c.iterator.Close()
 }
 
+// Err returns the error encountered while reading rows, rows may be truncated by it
+func (c *Collection) Err() error {
+	if c.iterator == nil {
+		return nil
+	}
+
+	return c.iterator.Err()
+}
+
 func (c *Collection) Scan(dest ...interface{}) error {
 	if c.loaded {
 		return errors.New("loaded collection can not scan")
@@ -327,8 +336,66 @@ func (c *Collection) LoadAll() bool {
 	return false
 }
 
+//LazyCollection iterate items of query page by page
+type LazyCollection struct {
+	builder *QueryBuilder
+	size    int
+	page    int
+	items   []*CollectionItem
+	index   int
+	current *CollectionItem
+	done    bool
+	err     error
+}
+
+func (l *LazyCollection) Next() bool {
+	if l.index >= len(l.items) {
+		if l.done || !l.fetch() {
+			return false
+		}
+	}
+	l.current = l.items[l.index]
+	l.index++
+
+	return true
+}
+
+func (l *LazyCollection) fetch() bool {
+	l.page++
+	coll, err := l.builder.Clone().ForPage(l.page, l.size).Get()
+	if err != nil {
+		l.err = err
+		l.done = true
+		return false
+	}
+	l.items = coll.GetItems()
+	l.index = 0
+	l.done = len(l.items) < l.size
+
+	return len(l.items) > 0
+}
+
+func (l *LazyCollection) Item() *CollectionItem {
+	return l.current
+}
+
+// Err returns the error stopped iteration
+func (l *LazyCollection) Err() error {
+	return l.err
+}
+
+// SqlRows is *sql.Rows or *Rows returned by connection
+type SqlRows interface {
+	Next() bool
+	Scan(dest ...interface{}) error
+	Columns() ([]string, error)
+	ColumnTypes() ([]*sql.ColumnType, error)
+	Err() error
+	Close() error
+}
+
 type RowsIterator struct {
-	rows    *sql.Rows
+	rows    SqlRows
 	types   []*sql.ColumnType
 	columns []string
 }
@@ -340,6 +407,12 @@ func (i *RowsIterator) Next() bool {
 func (i *RowsIterator) Close() error {
 	return i.rows.Close()
 }
+
+// Err returns the error encountered during iteration, such as the deadline of ctx exceeded
+func (i *RowsIterator) Err() error {
+	return i.rows.Err()
+}
+
 func (i *RowsIterator) Scan(dest ...interface{}) error {
 	return i.rows.Scan(dest...)
 }
@@ -371,7 +444,7 @@ func (i *RowsIterator) Read() map[string]interface{} {
 	return dataItem
 }
 
-func CollectRows(rows *sql.Rows) (*Collection, error) {
+func CollectRows(rows SqlRows) (*Collection, error) {
 	ite, err := NewRowsIterator(rows)
 	if err != nil {
 		return nil, err
@@ -383,15 +456,18 @@ func CollectRows(rows *sql.Rows) (*Collection, error) {
 	}, nil
 }
 
-func NewRowsIterator(rows *sql.Rows) (*RowsIterator, error) {
+// NewRowsIterator returns iterator of rows, rows are closed on error
+func NewRowsIterator(rows SqlRows) (*RowsIterator, error) {
 	types, err := rows.ColumnTypes()
 	if err != nil {
+		rows.Close()
 		return nil, err
 	}
 
 	cols, colErr := rows.Columns()
 
 	if colErr != nil {
+		rows.Close()
 		return nil, colErr
 	}
 
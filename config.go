@@ -1,15 +1,232 @@
 package database
 
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
 type ConnectionConfig struct {
 	Driver   string `yaml:"driver"`
 	Host     string `yaml:"host"`
 	Username string `yaml:"username"`
 	Password string `yaml:"password"`
 	Port     int    `yaml:"port"`
+	// Database is the file path of sqlite
 	Database string `yaml:"database"`
+	Prefix   string `yaml:"prefix"`
+	// Dsn is used as it is if given, other fields of dsn are ignored
+	Dsn       string `yaml:"dsn"`
+	Charset   string `yaml:"charset"`
+	Collation string `yaml:"collation"`
+	Timezone  string `yaml:"timezone"`
+	ParseTime bool   `yaml:"parse_time"`
+	// Options are extra parameters of dsn
+	Options map[string]string `yaml:"options"`
+	Pool    PoolConfig        `yaml:"pool"`
+	// Read and Write are hosts replacing Host of read and write pools,
+	// they are database paths for sqlite
+	Read  []string `yaml:"read"`
+	Write []string `yaml:"write"`
+	// Sticky makes reads use the write pool after a write of StickyContext
+	Sticky bool `yaml:"sticky"`
 }
 
 type Config struct {
 	Default     string                      `yaml:"default"`
 	Connections map[string]ConnectionConfig `yaml:"connections"`
 }
+
+// GetDsn returns dsn of the connection built by its driver
+func (c ConnectionConfig) GetDsn() (string, error) {
+	if c.Dsn != "" {
+		return c.Dsn, nil
+	}
+
+	switch c.Driver {
+	case "mysql":
+		return c.mysqlDsn(), nil
+	case "postgres", "pgx":
+		return c.postgresDsn(), nil
+	case "sqlserver", "mssql":
+		return c.sqlServerDsn(), nil
+	case "sqlite", "sqlite3":
+		return c.sqliteDsn()
+	}
+
+	return "", fmt.Errorf("config: unsupported driver [%s]", c.Driver)
+}
+
+// GetReadDsns returns dsn of every read host
+func (c ConnectionConfig) GetReadDsns() ([]string, error) {
+	var dsns []string
+	for _, host := range c.Read {
+		dsn, err := c.withHost(host).GetDsn()
+		if err != nil {
+			return nil, err
+		}
+		dsns = append(dsns, dsn)
+	}
+
+	return dsns, nil
+}
+
+// GetWriteDsns returns dsn of every write host, or dsn of Host if no write hosts
+func (c ConnectionConfig) GetWriteDsns() ([]string, error) {
+	if len(c.Write) < 1 {
+		dsn, err := c.GetDsn()
+		return []string{dsn}, err
+	}
+	var dsns []string
+	for _, host := range c.Write {
+		dsn, err := c.withHost(host).GetDsn()
+		if err != nil {
+			return nil, err
+		}
+		dsns = append(dsns, dsn)
+	}
+
+	return dsns, nil
+}
+
+func (c ConnectionConfig) withHost(host string) ConnectionConfig {
+	if c.Driver == "sqlite" || c.Driver == "sqlite3" {
+		c.Database = host
+	} else {
+		c.Host = host
+	}
+	c.Dsn = ""
+
+	return c
+}
+
+func (c ConnectionConfig) mysqlDsn() string {
+	params := c.params()
+	if c.Charset != "" {
+		params.Set("charset", c.Charset)
+	}
+	if c.Collation != "" {
+		params.Set("collation", c.Collation)
+	}
+	if c.Timezone != "" {
+		params.Set("loc", c.Timezone)
+	}
+	if c.ParseTime {
+		params.Set("parseTime", "true")
+	}
+
+	// the mysql driver takes user and password as they are, without unescaping
+	userInfo := c.Username
+	if c.Password != "" {
+		userInfo += ":" + c.Password
+	}
+
+	return fmt.Sprintf("%s@tcp(%s)/%s", userInfo, c.address(3306), c.Database) + encodeParams(params)
+}
+
+func (c ConnectionConfig) postgresDsn() string {
+	params := c.params()
+	if c.Charset != "" {
+		params.Set("client_encoding", c.Charset)
+	}
+	if c.Timezone != "" {
+		params.Set("timezone", c.Timezone)
+	}
+
+	return fmt.Sprintf("postgres://%s@%s/%s", c.userInfo(), c.address(5432), url.PathEscape(c.Database)) +
+		encodeParams(params)
+}
+
+func (c ConnectionConfig) sqlServerDsn() string {
+	params := c.params()
+	if c.Database != "" {
+		params.Set("database", c.Database)
+	}
+
+	return fmt.Sprintf("sqlserver://%s@%s", c.userInfo(), c.address(1433)) + encodeParams(params)
+}
+
+func (c ConnectionConfig) sqliteDsn() (string, error) {
+	if c.Database == "" {
+		return "", fmt.Errorf("config: database path of sqlite is empty")
+	}
+	params := c.params()
+	if c.Timezone != "" {
+		params.Set("_loc", c.Timezone)
+	}
+
+	return c.Database + encodeParams(params), nil
+}
+
+// userInfo returns escaped user info of url dsn
+func (c ConnectionConfig) userInfo() string {
+	if c.Password == "" {
+		return url.PathEscape(c.Username)
+	}
+
+	return url.UserPassword(c.Username, c.Password).String()
+}
+
+func (c ConnectionConfig) address(defaultPort int) string {
+	host, port := c.Host, c.Port
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	if port == 0 {
+		port = defaultPort
+	}
+
+	return host + ":" + strconv.Itoa(port)
+}
+
+func (c ConnectionConfig) params() url.Values {
+	params := url.Values{}
+	for k, v := range c.Options {
+		params.Set(k, v)
+	}
+
+	return params
+}
+
+// encodeParams encode params sorted by key, "?" is prepended if not empty
+func encodeParams(params url.Values) string {
+	if len(params) < 1 {
+		return ""
+	}
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = url.QueryEscape(k) + "=" + url.QueryEscape(params.Get(k))
+	}
+
+	return "?" + strings.Join(pairs, "&")
+}
+
+// ParseConfig parse yaml config
+func ParseConfig(data []byte) (Config, error) {
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("config: %v", err)
+	}
+
+	return config, nil
+}
+
+// LoadConfig load yaml config from file
+func LoadConfig(path string) (Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	return ParseConfig(data)
+}
@@ -1,9 +1,11 @@
 package database
 
 import (
+	"context"
 	"database/sql"
-	"errors"
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -43,38 +45,57 @@ func (d *OpenDBs) Remove(name string) {
 
 type DBEvent struct {
 	ev.Event
-	Sql         string
-	Type        string
-	Bindings    []interface{}
-	Err         error
+	Sql      string
+	Type     string
+	Bindings []interface{}
+	Err      error
+	// Microsecond is count of microseconds, use Duration instead
 	Microsecond time.Duration
+	Duration    time.Duration
+	// Connection is name of the connection registered in manager
+	Connection string
+	// Caller is "file:line" calling the query outside of the package,
+	// it's captured for slow queries only
+	Caller string
 }
 
 func (e *DBEvent) GetEventName() string {
 	return "enorith::db"
 }
 
+// GetRawSql returns sql with bindings, it's for debugging only
 func (e *DBEvent) GetRawSql() string {
-	sqlStr := e.Sql
-	for _, v := range e.Bindings {
-		var (
-			str    string
-			format string
-		)
-		switch v.(type) {
-		case int, int64, uint64:
-			format = "%d"
+	binding := func(n int) string {
+		if n < 1 || n > len(e.Bindings) {
+			return "?"
+		}
+		switch v := e.Bindings[n-1].(type) {
 		case string:
-			format = "'%s'"
-			break
+			return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+		case []byte:
+			return "'" + strings.ReplaceAll(string(v), "'", "''") + "'"
+		case nil:
+			return "null"
+		case time.Time:
+			return v.Format("'2006-01-02 15:04:05'")
+		default:
+			return fmt.Sprintf("%v", v)
 		}
-		str = fmt.Sprintf(format, v)
-		sqlStr = strings.Replace(sqlStr, "?", str, 1)
 	}
 
-	return sqlStr
+	// numbered placeholders of postgres and sql server
+	if numbered.MatchString(e.Sql) {
+		return numbered.ReplaceAllStringFunc(e.Sql, func(p string) string {
+			n, _ := strconv.Atoi(strings.TrimLeft(p, "$@p"))
+			return binding(n)
+		})
+	}
+
+	return replacePlaceholders(e.Sql, binding)
 }
 
+var numbered = regexp.MustCompile(`(\$|@p)\d+`)
+
 type ConnectionInterface interface {
 	GetDriver() string
 }
@@ -85,17 +106,47 @@ type Connection struct {
 	grammar Grammar
 	dsn     string
 	timeout time.Duration
+	// tablePrefix is prepended to every table of queries
+	tablePrefix string
+	pool        PoolConfig
+	// readDsns are used by selects, dsn is used by writes
+	readDsns []string
+	sticky   bool
+	// name is set by manager
+	name string
+	// queryLog is shared by clones of the connection
+	queryLog     *queryLog
+	interceptors []Interceptor
+	// tx is shared by connections of the same transaction
+	tx        *transaction
+	savepoint string
+	// mu guards lazily set db and grammar, connections are shared by derived builders
+	mu *sync.Mutex
 }
 
 func (c *Connection) GetDriver() string {
 	return c.driver
 }
 
+func (c *Connection) GetName() string {
+	return c.name
+}
+
 func (c *Connection) dbKey() string {
 	return c.driver + c.dsn
 }
 
 func (c *Connection) Close() error {
+	for _, dsn := range c.readDsns {
+		if opened, exists := openDBs.Get(c.driver + dsn); exists {
+			if e := opened.Close(); e != nil {
+				return e
+			}
+			openDBs.Remove(c.driver + dsn)
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if c.db != nil {
 		e := c.db.Close()
 		if e == nil {
@@ -108,51 +159,139 @@ func (c *Connection) Close() error {
 	return nil
 }
 
+// Clone connection, the cloned one runs in the same transaction
 func (c *Connection) Clone() *Connection {
-	return NewConnection(c.driver, c.dsn)
+	cloned := *c
+
+	return &cloned
+}
+
+// Rows of select, the timeout context of the query is released once closed.
+// Select returned *sql.Rows before, methods of *sql.Rows are promoted
+type Rows struct {
+	*sql.Rows
+	cancel context.CancelFunc
+	read   int64
+	// closed is called with count of read rows once closed
+	closed func(read int64)
+}
+
+func (r *Rows) Next() bool {
+	if r.Rows.Next() {
+		r.read++
+		return true
+	}
+
+	return false
 }
 
+func (r *Rows) Close() error {
+	err := r.Rows.Close()
+	r.cancel()
+	if r.closed != nil {
+		r.closed(r.read)
+		r.closed = nil
+	}
+
+	return err
+}
+
+// Select query without deadline, read rows are not counted in query log
 func (c *Connection) Select(sql string, bindings ...interface{}) (*sql.Rows, error) {
-	db, err := c.GetDB()
+	rows, err := c.SelectContext(withoutTimeout(context.Background()), sql, bindings...)
 	if err != nil {
 		return nil, err
 	}
 
-	startAt := time.Now().Nanosecond()
-	rows, queryErr := db.Query(sql, bindings...)
-	microsecond := time.Duration(time.Now().Nanosecond()-startAt) / time.Microsecond
+	return rows.Rows, nil
+}
+
+// SelectContext query with ctx, the default deadline of timeout is applied if ctx
+// has no deadline, and it covers reading of rows. Rows should be closed to release ctx
+func (c *Connection) SelectContext(ctx context.Context, sql string, bindings ...interface{}) (*Rows, error) {
+	info := QueryInfo{Type: "select", Sql: c.prepareSql(sql), Bindings: c.prepareBindings(bindings)}
+	result, err := c.intercept(ctx, info, c.query)
 
-	ev.BUS.Dispatch(&DBEvent{
-		Sql:         sql,
-		Type:        "select",
-		Err:         queryErr,
-		Bindings:    bindings,
-		Microsecond: microsecond,
-	})
+	return result.Rows, err
+}
+
+func (c *Connection) query(ctx context.Context, info QueryInfo) (Result, error) {
+	db, err := c.readExecutor(ctx)
+	if err != nil {
+		return Result{}, err
+	}
 
-	return rows, queryErr
+	// rows will be closed once ctx is canceled, so ctx is canceled by closing rows
+	ctx, cancel := c.withTimeout(ctx)
+	rows, err := db.QueryContext(ctx, info.Sql, info.Bindings...)
+	if err != nil {
+		cancel()
+		return Result{}, err
+	}
+
+	return Result{Rows: &Rows{Rows: rows, cancel: cancel}}, nil
 }
 
 func (c *Connection) Exec(sql string, bindings ...interface{}) (sql.Result, error) {
-	db, err := c.GetDB()
+	return c.ExecContext(context.Background(), sql, bindings...)
+}
+
+// ExecContext execute with ctx, the default deadline of timeout is applied if ctx has no deadline
+func (c *Connection) ExecContext(ctx context.Context, sql string, bindings ...interface{}) (sql.Result, error) {
+	info := QueryInfo{Type: "exec", Sql: c.prepareSql(sql), Bindings: c.prepareBindings(bindings)}
+	result, err := c.intercept(ctx, info, c.exec)
+
+	return result.Result, err
+}
+
+func (c *Connection) exec(ctx context.Context, info QueryInfo) (Result, error) {
+	db, err := c.executor()
 	if err != nil {
-		return nil, err
+		return Result{}, err
+	}
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	result, err := db.ExecContext(ctx, info.Sql, info.Bindings...)
+	if err == nil {
+		c.recordWrite(ctx)
 	}
 
-	result, queryErr := db.Exec(sql, bindings...)
+	return Result{Result: result}, err
+}
 
-	ev.BUS.Dispatch(&DBEvent{
-		Sql:      sql,
-		Type:     "exec",
-		Err:      queryErr,
-		Bindings: bindings,
-	})
+// dispatchEvent dispatch DBEvent of selects and execs
+func dispatchEvent(ctx context.Context, info QueryInfo, next InterceptHandler) (Result, error) {
+	if info.Type != "select" && info.Type != "exec" {
+		return next(ctx, info)
+	}
+	startAt := time.Now()
+	result, err := next(ctx, info)
+	duration := time.Since(startAt)
+
+	e := &DBEvent{
+		Sql:         info.Sql,
+		Type:        info.Type,
+		Err:         err,
+		Bindings:    info.Bindings,
+		Microsecond: duration / time.Microsecond,
+		Duration:    duration,
+		Connection:  info.Connection.name,
+	}
+	if isSlow(duration) {
+		e.Caller = caller()
+	}
+	ev.BUS.Dispatch(e)
 
-	return result, queryErr
+	return result, err
 }
 
 func (c *Connection) InsertGetId(sql string, bindings ...interface{}) (int64, error) {
-	result, execErr := c.Exec(sql, bindings...)
+	return c.InsertGetIdContext(context.Background(), sql, bindings...)
+}
+
+func (c *Connection) InsertGetIdContext(ctx context.Context, sql string, bindings ...interface{}) (int64, error) {
+	result, execErr := c.ExecContext(ctx, sql, bindings...)
 	if execErr != nil {
 		return 0, execErr
 	}
@@ -162,72 +301,49 @@ func (c *Connection) InsertGetId(sql string, bindings ...interface{}) (int64, er
 	return id, err
 }
 
-func (c *Connection) TransactionCall(handler func() error) error {
-	var err error
-
-	db, dbErr := c.GetDB()
-	if dbErr != nil {
-		err = dbErr
-	} else {
-		tx, txErr := db.Begin()
-		if txErr != nil {
-			err = txErr
-		} else {
-			err = c.callTxHandler(handler)
-			ce := tx.Commit()
-			if ce != nil {
-				return ce
-			}
-			if err != nil {
-				re := tx.Rollback()
-				if re != nil {
-					return re
-				}
-			}
-		}
+// GetDB returns the write pool
+func (c *Connection) GetDB() (*sql.DB, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	db, err := c.openDB(c.dsn)
+	if err != nil {
+		return nil, err
 	}
+	c.db = db
 
-	return err
-}
-
-func (c *Connection) callTxHandler(handler func() error) error {
-	var err error
-	defer func() {
-		if x := recover(); x != nil {
-			if e, ok := x.(error); ok {
-				err = e
-			}
-
-			if s, ok := x.(string); ok {
-				err = errors.New(s)
-			}
-		}
-	}()
-	err = handler()
-
-	return err
+	return db, nil
 }
 
-func (c *Connection) GetDB() (*sql.DB, error) {
-	key := c.dbKey()
+func (c *Connection) openDB(dsn string) (*sql.DB, error) {
+	key := c.driver + dsn
 
 	// if using opened connection
 	if opened, exits := openDBs.Get(key); exits {
-		c.db = opened
 		return opened, nil
 	}
 
-	db, err := sql.Open(c.driver, c.dsn)
+	db, err := sql.Open(c.driver, dsn)
 	if err != nil {
 		return nil, err
 	}
+	c.pool.apply(db)
 
 	openDBs.Put(key, db)
-	c.db = db
 	return db, err
 }
 
+// executor returns the transaction if connection is bound to one
+func (c *Connection) executor() (executor, error) {
+	if c.tx != nil {
+		return c.tx.tx, nil
+	}
+
+	return c.GetDB()
+}
+
 func (c *Connection) GetGrammar() (Grammar, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if c.grammar != nil {
 		return c.grammar, nil
 	}
@@ -235,11 +351,40 @@ func (c *Connection) GetGrammar() (Grammar, error) {
 	if !ge {
 		return nil, fmt.Errorf("grammar [%s] is not registed", c.driver)
 	}
+	if c.tablePrefix != "" {
+		grammar = grammar.WithTablePrefix(c.tablePrefix)
+	}
 
 	c.grammar = grammar
 	return grammar, nil
 }
 
+// prepareSql replace "?" placeholders by the ones of grammar's dialect
+func (c *Connection) prepareSql(sql string) string {
+	grammar, err := c.GetGrammar()
+	if err != nil {
+		return sql
+	}
+	if d, ok := grammar.(placeholderDialect); ok {
+		return replacePlaceholders(sql, d.placeholder)
+	}
+
+	return sql
+}
+
+// prepareBindings convert bindings by grammar's dialect
+func (c *Connection) prepareBindings(bindings []interface{}) []interface{} {
+	grammar, err := c.GetGrammar()
+	if err != nil {
+		return bindings
+	}
+	if d, ok := grammar.(bindingsDialect); ok {
+		return d.prepareBindings(bindings)
+	}
+
+	return bindings
+}
+
 func (c *Connection) GetTimeout() time.Duration {
 	if c.timeout == 0 {
 		return DefaultTimeout
@@ -253,11 +398,113 @@ func (c *Connection) Timeout(t time.Duration) *Connection {
 	return c
 }
 
-func NewConnection(driver, dsn string) *Connection {
-	return &Connection{
+func (c *Connection) GetTablePrefix() string {
+	return c.tablePrefix
+}
+
+func (c *Connection) TablePrefix(prefix string) *Connection {
+	c.tablePrefix = prefix
+	c.grammar = nil
+	return c
+}
+
+type noTimeoutKey struct{}
+
+// withoutTimeout returns ctx the default timeout of connection is not applied to
+func withoutTimeout(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noTimeoutKey{}, true)
+}
+
+func (c *Connection) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || ctx.Value(noTimeoutKey{}) != nil {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, c.GetTimeout())
+}
+
+// PoolConfig is settings of connection pool, zero values are not applied.
+// Connections of the same driver and dsn share one pool, settings of the
+// connection opening it first are applied
+type PoolConfig struct {
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+}
+
+func (p PoolConfig) apply(db *sql.DB) {
+	if p.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(p.MaxOpenConns)
+	}
+	if p.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(p.MaxIdleConns)
+	}
+	if p.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(p.ConnMaxLifetime)
+	}
+	if p.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(p.ConnMaxIdleTime)
+	}
+}
+
+type ConnectionOption func(c *Connection)
+
+func WithPool(pool PoolConfig) ConnectionOption {
+	return func(c *Connection) {
+		c.pool = pool
+	}
+}
+
+func WithMaxOpenConns(n int) ConnectionOption {
+	return func(c *Connection) {
+		c.pool.MaxOpenConns = n
+	}
+}
+
+func WithMaxIdleConns(n int) ConnectionOption {
+	return func(c *Connection) {
+		c.pool.MaxIdleConns = n
+	}
+}
+
+func WithConnMaxLifetime(d time.Duration) ConnectionOption {
+	return func(c *Connection) {
+		c.pool.ConnMaxLifetime = d
+	}
+}
+
+func WithConnMaxIdleTime(d time.Duration) ConnectionOption {
+	return func(c *Connection) {
+		c.pool.ConnMaxIdleTime = d
+	}
+}
+
+func (c *Connection) GetPoolConfig() PoolConfig {
+	return c.pool
+}
+
+// Stats returns statistics of the opened pool
+func (c *Connection) Stats() (sql.DBStats, error) {
+	db, err := c.GetDB()
+	if err != nil {
+		return sql.DBStats{}, err
+	}
+
+	return db.Stats(), nil
+}
+
+func NewConnection(driver, dsn string, options ...ConnectionOption) *Connection {
+	c := &Connection{
 		driver: driver,
 		dsn:    dsn,
+		mu:     &sync.Mutex{},
+	}
+	for _, option := range options {
+		option(c)
 	}
+
+	return c
 }
 
 func init() {
@@ -0,0 +1,70 @@
+//go:build cgo
+// +build cgo
+
+package database_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/enorith/database"
+)
+
+func TestConnection_RowsTimeout(t *testing.T) {
+	conn := sqliteConnection(t).Timeout(20 * time.Millisecond)
+	rows := make([]map[string]interface{}, 3)
+	for i := range rows {
+		rows[i] = map[string]interface{}{"name": "tom"}
+	}
+	if _, e := database.NewBuilder(conn).From("users").Insert(rows); e != nil {
+		t.Fatal(e)
+	}
+
+	it, e := database.NewBuilder(conn).From("users").GetRowsIterator()
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer it.Close()
+	var read int
+	for it.Next() {
+		time.Sleep(15 * time.Millisecond)
+		read++
+	}
+	if read != len(rows) {
+		t.Fatalf("iterator read %d rows beyond timeout, expect %d", read, len(rows))
+	}
+
+	selected, e := conn.Select("select * from users")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if e = selected.Close(); e != nil {
+		t.Fatal(e)
+	}
+}
+
+func TestConnection_GetTimeout(t *testing.T) {
+	conn := sqliteConnection(t).Timeout(20 * time.Millisecond)
+	coll, e := database.NewBuilder(conn).GetRaw("with recursive c(x) as (select 1 union all select x + 1 from c " +
+		"where x < 100000000) select x from c")
+	if e == nil {
+		t.Fatalf("get beyond timeout got %d rows without error", coll.Len())
+	}
+}
+
+func TestConnection_ConcurrentImmutable(t *testing.T) {
+	conn := sqliteConnection(t)
+	base := database.NewBuilder(conn).From("users").Immutable()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			if _, err := base.AndWhere("id", "=", id).Get(); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
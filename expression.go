@@ -0,0 +1,50 @@
+package database
+
+// Expression is raw sql with its own bindings, it's compiled as it is
+// wherever a column is accepted
+type Expression struct {
+	sql      string
+	bindings []interface{}
+}
+
+func (e Expression) GetSql() string {
+	return e.sql
+}
+
+func (e Expression) GetBindings() []interface{} {
+	return e.bindings
+}
+
+// RawPrefix marked a string as raw sql before
+//
+// Deprecated: prefixed strings are wrapped as identifiers now, use Raw instead
+var RawPrefix = '~'
+
+// Raw create expression of sql, placeholders of bindings should be "?".
+// It returned string prefixed by RawPrefix before
+func Raw(sql string, bindings ...interface{}) Expression {
+	return Expression{sql, bindings}
+}
+
+// expressionBindings collect bindings of expressions in values
+func expressionBindings(values ...interface{}) []interface{} {
+	var bindings []interface{}
+	for _, v := range values {
+		if e, ok := v.(Expression); ok {
+			bindings = append(bindings, e.bindings...)
+		}
+	}
+
+	return bindings
+}
+
+func columnName(column interface{}) string {
+	if s, ok := column.(string); ok {
+		return s
+	}
+	if e, ok := column.(Expression); ok {
+		return e.sql
+	}
+
+	return ""
+}
@@ -1,6 +1,6 @@
 module github.com/enorith/database
 
-go 1.13
+go 1.15
 
 require (
 	github.com/enorith/cache v0.0.1
@@ -9,4 +9,6 @@ require (
 	github.com/go-sql-driver/mysql v1.5.0
 	github.com/jinzhu/inflection v1.0.0
 	github.com/json-iterator/go v1.1.10
+	github.com/mattn/go-sqlite3 v1.14.22 // tests only, requires cgo
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -1,27 +1,120 @@
 package database
 
 import (
-	"bytes"
 	"fmt"
 	"github.com/enorith/supports/str"
+	"sort"
+	"strconv"
 	"strings"
 )
 
 var grammars map[string]Grammar
 
-var RawPrefix = '~'
-
 type Grammar interface {
 	Compile(s *QueryBuilder) string
 	CompileWheres(s *QueryBuilder, withKeyword bool) string
 	CompileExists(s *QueryBuilder) string
 	CompileCount(s *QueryBuilder, column ...string) string
+	CompileAggregate(s *QueryBuilder, fn string, column ...string) string
 	CompileInsertOne(table string, data map[string]interface{}) (sql string, bindings []interface{})
+	CompileInsertMany(table string, rows []map[string]interface{}) (sql string, bindings []interface{})
+	CompileInsertOrIgnore(table string, rows []map[string]interface{}) (sql string, bindings []interface{})
+	CompileUpsert(table string, rows []map[string]interface{}, uniqueBy []string, update []string) (sql string, bindings []interface{})
+	CompileUpdate(s *QueryBuilder, values map[string]interface{}) (sql string, bindings []interface{})
+	CompileDelete(s *QueryBuilder) (sql string, bindings []interface{})
+	// MaxPlaceholders is the max bindings count of one statement
+	MaxPlaceholders() int
+	// Wrap quote identifier of a column, like "table.column as alias",
+	// the table part is prefixed with table prefix
+	Wrap(value string) string
+	// WrapTable quote table name with table prefix, like "table as alias"
+	WrapTable(table string) string
+	GetTablePrefix() string
+	// WithTablePrefix returns a copy of the grammar using table prefix
+	WithTablePrefix(prefix string) Grammar
 }
 
 // SqlGrammar is sql compiler
 // compile QueryBuilder to sql string
 type SqlGrammar struct {
+	// dialect is the grammar embedding SqlGrammar, it takes over
+	// parts of compiling by implementing the dialect interfaces below
+	dialect     Grammar
+	tablePrefix string
+}
+
+// SqlGrammar compiles standard sql by default
+var _ Grammar = &SqlGrammar{}
+
+// quoteDialect quotes one segment of identifier, default by backticks
+type quoteDialect interface {
+	quoteIdentifier(segment string) string
+}
+
+// placeholderDialect numbers placeholders of a dialect, "?" is replaced
+// by placeholder(n) before executing
+type placeholderDialect interface {
+	placeholder(n int) string
+}
+
+// insertGetIdDialect compiles insert returning the inserted key,
+// for drivers not supporting LastInsertId
+type insertGetIdDialect interface {
+	CompileInsertGetId(table string, data map[string]interface{}, key string) (sql string, bindings []interface{})
+}
+
+// insertRowsDialect limits rows count of one insert statement
+type insertRowsDialect interface {
+	maxInsertRows() int
+}
+
+// dateDialect compiles part of date column, part is one of
+// "date", "time", "year", "month" and "day"
+type dateDialect interface {
+	dateColumn(part, column string) string
+}
+
+// bindingsDialect converts bindings to values supported by the database
+type bindingsDialect interface {
+	prepareBindings(bindings []interface{}) []interface{}
+}
+
+// replaceDialect compiles insert replacing existing rows of unique keys
+type replaceDialect interface {
+	CompileReplace(table string, rows []map[string]interface{}) (sql string, bindings []interface{})
+}
+
+// lockDialect compiles lock clause of a dialect
+type lockDialect interface {
+	lockClause(s *QueryBuilder) string
+}
+
+// lockHintDialect compiles lock as a hint of the table in from clause
+type lockHintDialect interface {
+	lockHint(s *QueryBuilder) string
+}
+
+// limitDialect compiles limit and offset of a dialect, topClause
+// is placed after "select" keyword
+type limitDialect interface {
+	topClause(s *QueryBuilder) string
+	limitClause(s *QueryBuilder) string
+}
+
+// cteDialect returns keyword of recursive common table expressions
+type cteDialect interface {
+	recursiveKeyword() string
+}
+
+// unionDialect wraps a part of unions having its own orders or limits
+type unionDialect interface {
+	unionPart(s *QueryBuilder, sql string) string
+}
+
+// savepointDialect compiles statement of savepoint action, which is one of
+// "create", "release" and "rollback", empty if the action is not supported
+type savepointDialect interface {
+	savepointStatement(action, name string) string
 }
 
 func (g *SqlGrammar) Compile(s *QueryBuilder) string {
@@ -30,9 +123,71 @@ func (g *SqlGrammar) Compile(s *QueryBuilder) string {
 		g.compileJoins(s) +
 		g.CompileWheres(s, true) +
 		g.compileGroups(s) +
+		g.compileHavings(s) +
 		g.compileOrders(s) +
-		g.compileLimit(s) +
-		g.compileOffset(s)
+		g.compileLimitOffset(s) +
+		g.compileLock(s)
+
+	if len(s.unions) > 0 {
+		sql = g.compileUnions(s, sql)
+	}
+
+	return g.compileCtes(s) + sql
+}
+
+func (g *SqlGrammar) compileCtes(s *QueryBuilder) string {
+	if len(s.ctes) < 1 {
+		return ""
+	}
+	keyword := "with"
+	var expressions []string
+
+	for _, c := range s.ctes {
+		query := strings.TrimSpace(g.Compile(c.query))
+		if c.recursive != nil {
+			keyword = "with recursive"
+			if d, ok := g.dialect.(cteDialect); ok {
+				keyword = d.recursiveKeyword()
+			}
+			query += " union all " + strings.TrimSpace(g.Compile(c.recursive))
+		}
+		// names are prefixed as tables, since they are referred as tables
+		name := g.WrapTable(c.name)
+		if len(c.columns) > 0 {
+			name += " (" + strings.Join(g.wrapColumns(c.columns), ", ") + ")"
+		}
+		expressions = append(expressions, fmt.Sprintf("%s as (%s)", name, query))
+	}
+
+	return fmt.Sprintf("%s %s ", keyword, strings.Join(expressions, ", "))
+}
+
+// compileUnions append unions to compiled select, orders and limits after
+// unions are applied to the combined result
+func (g *SqlGrammar) compileUnions(s *QueryBuilder, sql string) string {
+	sql = g.wrapUnion(s, sql)
+
+	for _, u := range s.unions {
+		keyword := "union"
+		if u.all {
+			keyword = "union all"
+		}
+		sql += fmt.Sprintf("%s %s", keyword, g.wrapUnion(u.query, g.Compile(u.query)))
+	}
+
+	combined := &QueryBuilder{orders: s.unionOrders, limit: s.unionLimit, offset: s.unionOffset}
+
+	return sql + g.compileOrders(combined) + g.compileLimitOffset(combined)
+}
+
+// wrapUnion wrap a part of union with parentheses when it has its own orders or limits
+func (g *SqlGrammar) wrapUnion(s *QueryBuilder, sql string) string {
+	if len(s.orders) > 0 || s.limit > -1 || s.offset > -1 {
+		if d, ok := g.dialect.(unionDialect); ok {
+			return d.unionPart(s, sql)
+		}
+		return "(" + strings.TrimSpace(sql) + ") "
+	}
 
 	return sql
 }
@@ -42,53 +197,242 @@ func (g *SqlGrammar) compileColumns(s *QueryBuilder) string {
 	if len(s.columns) < 1 {
 		col = "* "
 	} else {
-		col = strings.Join(g.wrapColumns(s.columns), ", ") + " "
+		col = strings.Join(g.wrapExpressions(s.columns), ", ") + " "
+	}
+	if d, ok := g.dialect.(limitDialect); ok {
+		col = d.topClause(s) + col
 	}
 
 	return "select " + col
 }
 
 func (g *SqlGrammar) compileFrom(s *QueryBuilder) string {
-	return fmt.Sprintf("from %s ", WrapValue(s.from))
+	if s.fromRaw != nil {
+		return fmt.Sprintf("from %s ", s.fromRaw.sql)
+	}
+	if d, ok := g.dialect.(lockHintDialect); ok && s.lock != "" {
+		return fmt.Sprintf("from %s %s", g.WrapTable(s.from), d.lockHint(s))
+	}
+	return fmt.Sprintf("from %s ", g.WrapTable(s.from))
 }
 
 func (g *SqlGrammar) CompileExists(s *QueryBuilder) string {
-	return fmt.Sprintf("select exists(%s) as `exists`", g.Compile(s))
+	return fmt.Sprintf("select exists(%s) as %s", g.Compile(s), g.Wrap("exists"))
 }
 
 func (g *SqlGrammar) CompileCount(s *QueryBuilder, column ...string) string {
-	var col string
-	if len(column) > 0 {
-		col = fmt.Sprintf("count(%s) as `aggregate`", WrapValue(column[0]))
-	} else {
-		col = "count(*) as `aggregate`"
+	return g.CompileAggregate(s, "count", column...)
+}
+
+// CompileAggregate compile aggregate function of column, select from
+// union result if the query has unions
+func (g *SqlGrammar) CompileAggregate(s *QueryBuilder, fn string, column ...string) string {
+	col := "*"
+	if len(column) > 0 && column[0] != "" && column[0] != "*" {
+		col = g.Wrap(column[0])
+	}
+	aggregate := Raw(fmt.Sprintf("%s(%s) as %s", fn, col, g.Wrap("aggregate")))
+
+	if len(s.unions) > 0 {
+		return fmt.Sprintf("select %s from (%s) as %s", aggregate.sql, strings.TrimSpace(g.Compile(s)), g.Wrap("temp_table"))
 	}
 
-	return g.Compile(s.Select(Raw(col)))
+	return g.Compile(s.Clone().Select(aggregate))
 }
 
 func (g *SqlGrammar) CompileInsertOne(table string, data map[string]interface{}) (sql string, bindings []interface{}) {
+	return g.CompileInsertMany(table, []map[string]interface{}{data})
+}
+
+func (g *SqlGrammar) CompileInsertMany(table string, rows []map[string]interface{}) (sql string, bindings []interface{}) {
+	return g.compileInsert("insert into", table, rows)
+}
+
+// CompileInsertOrIgnore is not supported by default, empty sql is returned
+func (g *SqlGrammar) CompileInsertOrIgnore(table string, rows []map[string]interface{}) (sql string, bindings []interface{}) {
+	return "", nil
+}
+
+// CompileUpsert is not supported by default, empty sql is returned
+func (g *SqlGrammar) CompileUpsert(table string, rows []map[string]interface{}, uniqueBy []string, update []string) (sql string, bindings []interface{}) {
+	return "", nil
+}
+
+func (g *SqlGrammar) compileInsert(verb, table string, rows []map[string]interface{}) (sql string, bindings []interface{}) {
+	columns := insertColumns(rows)
+
+	return fmt.Sprintf("%s %s (%s) values %s", verb, g.WrapTable(table),
+		strings.Join(g.wrapColumns(columns), ", "), g.compileInsertValues(columns, rows, &bindings)), bindings
+}
+
+// upsertColumns returns columns to update on conflict,
+// default to all inserting columns except unique ones
+func (g *SqlGrammar) upsertColumns(rows []map[string]interface{}, uniqueBy []string, update []string) []string {
+	if len(update) > 0 {
+		return update
+	}
+	unique := make(map[string]bool)
+	for _, column := range uniqueBy {
+		unique[column] = true
+	}
+	var columns []string
+	for _, column := range insertColumns(rows) {
+		if !unique[column] {
+			columns = append(columns, column)
+		}
+	}
+
+	return columns
+}
+
+// compileOnConflict compile upsert of "on conflict" clause, which requires uniqueBy
+// as conflict target, empty sql is returned without uniqueBy. It's compiled
+// to "do nothing" if there is nothing to update
+func (g *SqlGrammar) compileOnConflict(table string, rows []map[string]interface{}, uniqueBy []string, update []string) (sql string, bindings []interface{}) {
+	if len(uniqueBy) < 1 {
+		return "", nil
+	}
+	sql, bindings = g.compileInsert("insert into", table, rows)
+	sql += fmt.Sprintf(" on conflict (%s) ", strings.Join(g.wrapColumns(uniqueBy), ", "))
+	columns := g.upsertColumns(rows, uniqueBy, update)
+	if len(columns) < 1 {
+		return sql + "do nothing", bindings
+	}
+	var sets []string
+	for _, column := range columns {
+		wrapped := g.Wrap(column)
+		sets = append(sets, fmt.Sprintf("%s = excluded.%s", wrapped, wrapped))
+	}
+
+	return sql + "do update set " + strings.Join(sets, ", "), bindings
+}
+
+func (g *SqlGrammar) compileInsertValues(columns []string, rows []map[string]interface{}, bindings *[]interface{}) string {
+	placeholder := "(" + strings.Join(str.Duplicate("?", len(columns)), ", ") + ")"
+	values := make([]string, len(rows))
+
+	for k, row := range rows {
+		values[k] = placeholder
+		for _, column := range columns {
+			*bindings = append(*bindings, row[column])
+		}
+	}
+
+	return strings.Join(values, ", ")
+}
+
+func (g *SqlGrammar) MaxPlaceholders() int {
+	return 65535
+}
+
+func (g *SqlGrammar) CompileUpdate(s *QueryBuilder, values map[string]interface{}) (sql string, bindings []interface{}) {
+	sets, setValues := g.compileSets(values)
+
+	sql = fmt.Sprintf("update %s %sset %s ", g.WrapTable(s.from), g.compileJoins(s), sets) +
+		g.CompileWheres(s, true) +
+		g.compileOrders(s) +
+		g.compileLimit(s)
+
+	bindings = append(s.joinBindings(), setValues...)
+	bindings = append(bindings, flatBindings(s.bindings)...)
+
+	return sql, append(bindings, orderBindings(s.orders)...)
+}
+
+func (g *SqlGrammar) compileSets(values map[string]interface{}) (string, []interface{}) {
 	var (
-		cols      []string
-		values    []interface{}
-		countAttr int
+		sets      []string
+		setValues []interface{}
 	)
-	for k, v := range data {
-		cols = append(cols, k)
-		values = append(values, v)
-		countAttr++
+	for _, column := range sortedKeys(values) {
+		if raw, ok := values[column].(Expression); ok {
+			sets = append(sets, fmt.Sprintf("%s = %s", g.Wrap(column), raw.sql))
+			setValues = append(setValues, raw.bindings...)
+		} else {
+			sets = append(sets, fmt.Sprintf("%s = ?", g.Wrap(column)))
+			setValues = append(setValues, values[column])
+		}
+	}
+
+	return strings.Join(sets, ", "), setValues
+}
+
+// compileUpdateByKey compile joins, orders and limit to a subquery selecting
+// row key, for databases not supporting them in update statement
+func (g *SqlGrammar) compileUpdateByKey(s *QueryBuilder, values map[string]interface{}, key string) (sql string, bindings []interface{}) {
+	sets, setValues := g.compileSets(values)
+	sql = fmt.Sprintf("update %s set %s ", g.WrapTable(s.from), sets)
+	if !g.needsKeySubquery(s) {
+		return sql + g.CompileWheres(s, true), append(setValues, flatBindings(s.bindings)...)
+	}
+	sub, subBindings := g.compileKeySubquery(s, key)
+
+	return sql + sub, append(setValues, subBindings...)
+}
+
+func (g *SqlGrammar) compileDeleteByKey(s *QueryBuilder, key string) (sql string, bindings []interface{}) {
+	sql = fmt.Sprintf("delete from %s ", g.WrapTable(s.from))
+	if !g.needsKeySubquery(s) {
+		return sql + g.CompileWheres(s, true), flatBindings(s.bindings)
+	}
+	sub, subBindings := g.compileKeySubquery(s, key)
+
+	return sql + sub, subBindings
+}
+
+func (g *SqlGrammar) needsKeySubquery(s *QueryBuilder) bool {
+	return len(s.joins) > 0 || len(s.orders) > 0 || s.limit > -1
+}
+
+func (g *SqlGrammar) compileKeySubquery(s *QueryBuilder, key string) (string, []interface{}) {
+	table := s.from
+	if alias := strings.Index(table, " as "); alias > -1 {
+		table = table[alias+4:]
+	}
+	query := s.Clone().Select(table + "." + key)
+	bindings := append(query.joinBindings(), flatBindings(query.bindings)...)
+
+	return fmt.Sprintf("where %s in (%s) ", g.Wrap(key), strings.TrimSpace(g.Compile(query))),
+		append(bindings, orderBindings(query.orders)...)
+}
+
+func (g *SqlGrammar) CompileDelete(s *QueryBuilder) (sql string, bindings []interface{}) {
+	table := g.WrapTable(s.from)
+	wheres := g.CompileWheres(s, true)
+
+	// multiple table delete does not support order and limit
+	if len(s.joins) > 0 {
+		sql = fmt.Sprintf("delete %s from %s %s", table, table, g.compileJoins(s)) + wheres
+
+		return sql, append(s.joinBindings(), flatBindings(s.bindings)...)
 	}
-	placeholder := strings.Join(str.Duplicate("?", countAttr), ",")
+	sql = fmt.Sprintf("delete from %s ", table) + wheres + g.compileOrders(s) + g.compileLimit(s)
 
-	return fmt.Sprintf("insert into `%s`(`%s`) values(%s)",
-		table, strings.Join(cols, "`,`"), placeholder), values
+	return sql, append(flatBindings(s.bindings), orderBindings(s.orders)...)
 }
 
 func (g *SqlGrammar) CompileWheres(s *QueryBuilder, withKeyword bool) string {
+	where := g.compileConditions(s.wheres, s.inLens)
+
+	if len(where) > 0 && withKeyword {
+		where = "where " + where
+	}
+	return where
+}
+
+func (g *SqlGrammar) compileHavings(s *QueryBuilder) string {
+	if len(s.havings) < 1 {
+		return ""
+	}
+
+	return "having " + g.compileConditions(s.havings, nil)
+}
+
+func (g *SqlGrammar) compileConditions(conditions [][5]string, inLens []int) string {
 	where := ""
 	inIndex := 0
 
-	for k, w := range s.wheres {
+	for k, w := range conditions {
 		var (
 			andOr       string
 			placeholder string
@@ -105,19 +449,30 @@ func (g *SqlGrammar) CompileWheres(s *QueryBuilder, withKeyword bool) string {
 			placeholder = "? "
 		}
 		if whereType == whereIn {
-			placeholder = "(" + strings.Join(str.Duplicate("?", s.inLens[inIndex]), ",") + ") "
+			length := inLens[inIndex]
 			inIndex++
+			// empty "in ()" is a syntax error
+			if length == 0 {
+				if operator == "in" {
+					where += andOr + "0 = 1 "
+				} else {
+					where += andOr + "1 = 1 "
+				}
+				continue
+			}
+			placeholder = "(" + strings.Join(str.Duplicate("?", length), ",") + ") "
 		}
 		if whereType == whereBetween {
 			placeholder = "? and ? "
 		}
 
 		if whereType == whereColumn {
-			placeholder = WrapValue(other) + " "
+			placeholder = g.Wrap(other) + " "
 		}
 
 		if whereType == whereNest {
-			column = Raw("(" + column + ")")
+			where += fmt.Sprintf("%s(%s) ", andOr, column)
+			continue
 		}
 
 		if whereType == whereNull {
@@ -130,15 +485,35 @@ func (g *SqlGrammar) CompileWheres(s *QueryBuilder, withKeyword bool) string {
 			placeholder = " "
 		}
 
-		where += fmt.Sprintf("%s%s %s %s", andOr, WrapValue(column), operator, placeholder)
-	}
+		if whereType == whereRaw {
+			where += fmt.Sprintf("%s%s ", andOr, column)
+			continue
+		}
 
-	if len(where) > 0 && withKeyword {
-		where = "where " + where
+		if whereType == whereDate {
+			where += fmt.Sprintf("%s%s %s ? ", andOr, g.compileDateColumn(other, column), operator)
+			continue
+		}
+
+		if whereType == whereExists {
+			where += fmt.Sprintf("%s%s (%s) ", andOr, operator, strings.TrimSpace(column))
+			continue
+		}
+
+		where += fmt.Sprintf("%s%s %s %s", andOr, g.Wrap(column), operator, placeholder)
 	}
+
 	return where
 }
 
+func (g *SqlGrammar) compileDateColumn(part, column string) string {
+	if d, ok := g.dialect.(dateDialect); ok {
+		return d.dateColumn(part, g.Wrap(column))
+	}
+
+	return fmt.Sprintf("%s(%s)", part, g.Wrap(column))
+}
+
 func (g *SqlGrammar) compileOrders(s *QueryBuilder) string {
 	if len(s.orders) < 1 {
 		return ""
@@ -146,12 +521,20 @@ func (g *SqlGrammar) compileOrders(s *QueryBuilder) string {
 	var orders []string
 
 	for _, v := range s.orders {
-		orders = append(orders, fmt.Sprintf("%s %s", WrapValue(v[0]), v[1]))
+		orders = append(orders, strings.TrimSpace(g.wrapExpression(v.column)+" "+v.direction))
 	}
 
 	return fmt.Sprintf("order by %s ", strings.Join(orders, ", "))
 }
 
+func (g *SqlGrammar) compileLimitOffset(s *QueryBuilder) string {
+	if d, ok := g.dialect.(limitDialect); ok {
+		return d.limitClause(s)
+	}
+
+	return g.compileLimit(s) + g.compileOffset(s)
+}
+
 func (g *SqlGrammar) compileLimit(s *QueryBuilder) string {
 	if s.limit < 0 {
 		return ""
@@ -166,12 +549,23 @@ func (g *SqlGrammar) compileOffset(s *QueryBuilder) string {
 	return fmt.Sprintf("offset %d ", s.offset)
 }
 
+func (g *SqlGrammar) compileLock(s *QueryBuilder) string {
+	if s.lock == "" {
+		return ""
+	}
+	if d, ok := g.dialect.(lockDialect); ok {
+		return d.lockClause(s)
+	}
+
+	return strings.TrimSpace(fmt.Sprintf("for %s %s", s.lock, s.lockModifier)) + " "
+}
+
 func (g *SqlGrammar) compileGroups(s *QueryBuilder) string {
 	if len(s.groups) < 1 {
 		return ""
 	}
 
-	return fmt.Sprintf("group by %s ", strings.Join(g.wrapColumns(s.groups), ","))
+	return fmt.Sprintf("group by %s ", strings.Join(g.wrapExpressions(s.groups), ","))
 }
 
 func (g *SqlGrammar) compileJoins(s *QueryBuilder) string {
@@ -184,7 +578,7 @@ func (g *SqlGrammar) compileJoins(s *QueryBuilder) string {
 	for _, join := range s.joins {
 		wheres := g.CompileWheres(join.QueryBuilder, false)
 
-		tab := WrapValue(join.table)
+		tab := g.WrapTable(join.table)
 		joinString := tab
 		if len(join.joins) > 0 {
 			joinString = fmt.Sprintf("(%s %s)", tab, g.compileJoins(join.QueryBuilder))
@@ -200,53 +594,610 @@ func (g *SqlGrammar) wrapColumns(columns []string) []string {
 
 	var result []string
 	for _, v := range columns {
-		result = append(result, WrapValue(v))
+		result = append(result, g.Wrap(v))
 	}
 
 	return result
 }
 
-func WrapValue(value string) string {
-	b := []byte(value)
+// wrapExpression wrap a column, expressions are compiled as they are
+func (g *SqlGrammar) wrapExpression(column interface{}) string {
+	if e, ok := column.(Expression); ok {
+		return e.sql
+	}
+
+	return g.Wrap(columnName(column))
+}
 
-	if b[0] == byte(RawPrefix) {
-		return string(b[1:])
+func (g *SqlGrammar) wrapExpressions(columns []interface{}) []string {
+	var result []string
+	for _, v := range columns {
+		result = append(result, g.wrapExpression(v))
 	}
 
-	if bytes.Contains(b, []byte(" as ")) {
-		partials := bytes.SplitN(b, []byte(" as "), 2)
+	return result
+}
 
-		return string(bytes.Join([][]byte{wrapBytes(partials[0]), wrapBytes(partials[1])}, []byte(" as ")))
+// insertColumns collect columns of all rows in a stable order,
+// missing values of a row will be inserted as null
+func insertColumns(rows []map[string]interface{}) []string {
+	all := make(map[string]interface{})
+	for _, row := range rows {
+		for k := range row {
+			all[k] = nil
+		}
 	}
 
-	return string(wrapBytes(b))
+	return sortedKeys(all)
 }
 
-func Raw(value string) string {
-	return string(RawPrefix) + value
+func sortedKeys(data map[string]interface{}) []string {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
 }
 
-func wrapBytes(b []byte) []byte {
-	var result = []byte("`")
-	if bytes.IndexByte(b, '.') > -1 {
-		bs := bytes.SplitN(b, []byte("."), 2)
-		byb := bytes.Join(bs, []byte("`.`"))
-		result = append(result, byb...)
-	} else {
-		result = append(result, b...)
+func (g *SqlGrammar) Wrap(value string) string {
+	if alias := strings.Index(value, " as "); alias > -1 {
+		return g.Wrap(value[:alias]) + " as " + g.quote(value[alias+4:])
+	}
+	segments := strings.Split(value, ".")
+	for i, segment := range segments {
+		if i == len(segments)-2 {
+			segment = g.tablePrefix + segment
+		}
+		segments[i] = g.quote(segment)
+	}
+
+	return strings.Join(segments, ".")
+}
+
+// WrapTable quote table with prefix, the alias is prefixed as well,
+// so columns qualified by alias refer to it correctly
+func (g *SqlGrammar) WrapTable(table string) string {
+	if alias := strings.Index(table, " as "); alias > -1 {
+		return g.WrapTable(table[:alias]) + " as " + g.quote(g.tablePrefix+table[alias+4:])
+	}
+	segments := strings.Split(table, ".")
+	for i, segment := range segments {
+		if i == len(segments)-1 {
+			segment = g.tablePrefix + segment
+		}
+		segments[i] = g.quote(segment)
+	}
+
+	return strings.Join(segments, ".")
+}
+
+func (g *SqlGrammar) quote(segment string) string {
+	if segment == "*" || segment == "" {
+		return segment
+	}
+	if d, ok := g.dialect.(quoteDialect); ok {
+		return d.quoteIdentifier(segment)
 	}
 
-	return append(result, '`')
+	return "`" + strings.ReplaceAll(segment, "`", "``") + "`"
+}
+
+// compileSavepoint compile statement of savepoint action of nested transactions
+func (g *SqlGrammar) compileSavepoint(action, name string) string {
+	if d, ok := g.dialect.(savepointDialect); ok {
+		return d.savepointStatement(action, name)
+	}
+	switch action {
+	case "release":
+		return "release savepoint " + name
+	case "rollback":
+		return "rollback to savepoint " + name
+	}
+
+	return "savepoint " + name
+}
+
+func (g *SqlGrammar) GetTablePrefix() string {
+	return g.tablePrefix
+}
+
+// WithTablePrefix returns a new default grammar using table prefix
+func (g *SqlGrammar) WithTablePrefix(prefix string) Grammar {
+	return &SqlGrammar{tablePrefix: prefix}
+}
+
+// WrapValue quote value by backticks
+//
+// Deprecated: it ignores the dialect and table prefix, use Grammar.Wrap instead
+func WrapValue(value string) string {
+	return (&SqlGrammar{}).Wrap(value)
 }
 
 type MysqlGrammar struct {
 	SqlGrammar
 }
 
+func NewMysqlGrammar() *MysqlGrammar {
+	g := &MysqlGrammar{}
+	g.dialect = g
+
+	return g
+}
+
+// WithTablePrefix returns a new mysql grammar using table prefix
+func (g *MysqlGrammar) WithTablePrefix(prefix string) Grammar {
+	n := NewMysqlGrammar()
+	n.tablePrefix = prefix
+
+	return n
+}
+
+// lockClause compile shared lock to "lock in share mode",
+// or "for share" of mysql 8 if modifier is given
+func (g *MysqlGrammar) lockClause(s *QueryBuilder) string {
+	if s.lock == lockShare && s.lockModifier == "" {
+		return "lock in share mode "
+	}
+
+	return strings.TrimSpace(fmt.Sprintf("for %s %s", s.lock, s.lockModifier)) + " "
+}
+
+func (g *MysqlGrammar) CompileReplace(table string, rows []map[string]interface{}) (sql string, bindings []interface{}) {
+	return g.compileInsert("replace into", table, rows)
+}
+
+func (g *MysqlGrammar) CompileInsertOrIgnore(table string, rows []map[string]interface{}) (sql string, bindings []interface{}) {
+	return g.compileInsert("insert ignore into", table, rows)
+}
+
+// CompileUpsert compile to "on duplicate key update", uniqueBy is ignored
+// since mysql detects duplication by all unique indexes of the table.
+// It's compiled to "insert ignore" if there is nothing to update
+func (g *MysqlGrammar) CompileUpsert(table string, rows []map[string]interface{}, uniqueBy []string, update []string) (sql string, bindings []interface{}) {
+	columns := g.upsertColumns(rows, uniqueBy, update)
+	if len(columns) < 1 {
+		return g.CompileInsertOrIgnore(table, rows)
+	}
+	sql, bindings = g.compileInsert("insert into", table, rows)
+	var sets []string
+	for _, column := range columns {
+		wrapped := g.Wrap(column)
+		sets = append(sets, fmt.Sprintf("%s = values(%s)", wrapped, wrapped))
+	}
+
+	return sql + " on duplicate key update " + strings.Join(sets, ", "), bindings
+}
+
 type SqliteGrammar struct {
 	SqlGrammar
 }
 
+func NewSqliteGrammar() *SqliteGrammar {
+	g := &SqliteGrammar{}
+	g.dialect = g
+
+	return g
+}
+
+// WithTablePrefix returns a new sqlite grammar using table prefix
+func (g *SqliteGrammar) WithTablePrefix(prefix string) Grammar {
+	n := NewSqliteGrammar()
+	n.tablePrefix = prefix
+
+	return n
+}
+
+func (g *SqliteGrammar) quoteIdentifier(segment string) string {
+	return `"` + strings.ReplaceAll(segment, `"`, `""`) + `"`
+}
+
+func (g *SqliteGrammar) topClause(s *QueryBuilder) string {
+	return ""
+}
+
+// limitClause compile offset without limit to "limit -1 offset n"
+func (g *SqliteGrammar) limitClause(s *QueryBuilder) string {
+	if s.offset > -1 && s.limit < 0 {
+		return fmt.Sprintf("limit -1 offset %d ", s.offset)
+	}
+
+	return g.compileLimit(s) + g.compileOffset(s)
+}
+
+// unionPart select from sub query, sqlite does not support parentheses in unions
+func (g *SqliteGrammar) unionPart(s *QueryBuilder, sql string) string {
+	return fmt.Sprintf("select * from (%s) ", strings.TrimSpace(sql))
+}
+
+func (g *SqliteGrammar) dateColumn(part, column string) string {
+	switch part {
+	case "date":
+		return "strftime('%Y-%m-%d', " + column + ")"
+	case "time":
+		return "strftime('%H:%M:%S', " + column + ")"
+	case "year":
+		return "cast(strftime('%Y', " + column + ") as integer)"
+	case "month":
+		return "cast(strftime('%m', " + column + ") as integer)"
+	}
+
+	return "cast(strftime('%d', " + column + ") as integer)"
+}
+
+// prepareBindings convert booleans to 1 and 0, sqlite has no boolean type
+func (g *SqliteGrammar) prepareBindings(bindings []interface{}) []interface{} {
+	prepared := make([]interface{}, len(bindings))
+	for i, v := range bindings {
+		prepared[i] = v
+		if b, ok := v.(bool); ok {
+			prepared[i] = 0
+			if b {
+				prepared[i] = 1
+			}
+		}
+	}
+
+	return prepared
+}
+
+func (g *SqliteGrammar) CompileReplace(table string, rows []map[string]interface{}) (sql string, bindings []interface{}) {
+	return g.compileInsert("insert or replace into", table, rows)
+}
+
+// CompileUpdate compile joins, orders and limit to a subquery of rowid
+func (g *SqliteGrammar) CompileUpdate(s *QueryBuilder, values map[string]interface{}) (sql string, bindings []interface{}) {
+	return g.compileUpdateByKey(s, values, "rowid")
+}
+
+func (g *SqliteGrammar) CompileDelete(s *QueryBuilder) (sql string, bindings []interface{}) {
+	return g.compileDeleteByKey(s, "rowid")
+}
+
+// lockClause is ignored by sqlite, since sqlite locks the whole database
+// for writing in a transaction, there is no row lock
+func (g *SqliteGrammar) lockClause(s *QueryBuilder) string {
+	return ""
+}
+
+func (g *SqliteGrammar) CompileInsertOrIgnore(table string, rows []map[string]interface{}) (sql string, bindings []interface{}) {
+	return g.compileInsert("insert or ignore into", table, rows)
+}
+
+func (g *SqliteGrammar) CompileUpsert(table string, rows []map[string]interface{}, uniqueBy []string, update []string) (sql string, bindings []interface{}) {
+	return g.compileOnConflict(table, rows, uniqueBy, update)
+}
+
+// MaxPlaceholders of sqlite before 3.32.0
+func (g *SqliteGrammar) MaxPlaceholders() int {
+	return 999
+}
+
+type PostgresGrammar struct {
+	SqlGrammar
+}
+
+func NewPostgresGrammar() *PostgresGrammar {
+	g := &PostgresGrammar{}
+	g.dialect = g
+
+	return g
+}
+
+// WithTablePrefix returns a new postgres grammar using table prefix
+func (g *PostgresGrammar) WithTablePrefix(prefix string) Grammar {
+	n := NewPostgresGrammar()
+	n.tablePrefix = prefix
+
+	return n
+}
+
+func (g *PostgresGrammar) quoteIdentifier(segment string) string {
+	return `"` + strings.ReplaceAll(segment, `"`, `""`) + `"`
+}
+
+func (g *PostgresGrammar) placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+func (g *PostgresGrammar) topClause(s *QueryBuilder) string {
+	return ""
+}
+
+// limitClause compile offset without limit to "offset n"
+func (g *PostgresGrammar) limitClause(s *QueryBuilder) string {
+	if s.offset > -1 && s.limit < 0 {
+		return fmt.Sprintf("offset %d ", s.offset)
+	}
+
+	return g.compileLimit(s) + g.compileOffset(s)
+}
+
+func (g *PostgresGrammar) CompileInsertOrIgnore(table string, rows []map[string]interface{}) (sql string, bindings []interface{}) {
+	sql, bindings = g.compileInsert("insert into", table, rows)
+
+	return sql + " on conflict do nothing", bindings
+}
+
+func (g *PostgresGrammar) CompileUpsert(table string, rows []map[string]interface{}, uniqueBy []string, update []string) (sql string, bindings []interface{}) {
+	return g.compileOnConflict(table, rows, uniqueBy, update)
+}
+
+// CompileInsertGetId compile insert returning key, postgres drivers do not support LastInsertId
+func (g *PostgresGrammar) CompileInsertGetId(table string, data map[string]interface{}, key string) (sql string, bindings []interface{}) {
+	sql, bindings = g.CompileInsertOne(table, data)
+
+	return sql + " returning " + g.Wrap(key), bindings
+}
+
+// CompileUpdate compile joins, orders and limit to a subquery of ctid,
+// since postgres update does not support them
+func (g *PostgresGrammar) CompileUpdate(s *QueryBuilder, values map[string]interface{}) (sql string, bindings []interface{}) {
+	return g.compileUpdateByKey(s, values, "ctid")
+}
+
+func (g *PostgresGrammar) CompileDelete(s *QueryBuilder) (sql string, bindings []interface{}) {
+	return g.compileDeleteByKey(s, "ctid")
+}
+
+func (g *PostgresGrammar) dateColumn(part, column string) string {
+	switch part {
+	case "date", "time":
+		return column + "::" + part
+	}
+
+	return fmt.Sprintf("extract(%s from %s)", part, column)
+}
+
+type SqlServerGrammar struct {
+	SqlGrammar
+}
+
+func NewSqlServerGrammar() *SqlServerGrammar {
+	g := &SqlServerGrammar{}
+	g.dialect = g
+
+	return g
+}
+
+// WithTablePrefix returns a new sql server grammar using table prefix
+func (g *SqlServerGrammar) WithTablePrefix(prefix string) Grammar {
+	n := NewSqlServerGrammar()
+	n.tablePrefix = prefix
+
+	return n
+}
+
+func (g *SqlServerGrammar) quoteIdentifier(segment string) string {
+	return "[" + strings.ReplaceAll(segment, "]", "]]") + "]"
+}
+
+func (g *SqlServerGrammar) placeholder(n int) string {
+	return "@p" + strconv.Itoa(n)
+}
+
+// MaxPlaceholders of sql server is 2100 parameters per request
+func (g *SqlServerGrammar) MaxPlaceholders() int {
+	return 2100
+}
+
+// maxInsertRows of a table value constructor is 1000
+func (g *SqlServerGrammar) maxInsertRows() int {
+	return 1000
+}
+
+func (g *SqlServerGrammar) recursiveKeyword() string {
+	return "with"
+}
+
+// usesTop is true if only limit is given, sql server compiles it to "top",
+// the combined result of unions has no table and uses "fetch next" instead
+func (g *SqlServerGrammar) usesTop(s *QueryBuilder) bool {
+	return s.limit > -1 && s.offset < 0 && (s.from != "" || s.fromRaw != nil)
+}
+
+func (g *SqlServerGrammar) topClause(s *QueryBuilder) string {
+	if g.usesTop(s) {
+		return fmt.Sprintf("top %d ", s.limit)
+	}
+
+	return ""
+}
+
+// limitClause compile to "offset ... fetch next", which requires orders
+func (g *SqlServerGrammar) limitClause(s *QueryBuilder) string {
+	if g.usesTop(s) || (s.limit < 0 && s.offset < 0) {
+		return ""
+	}
+	var sql string
+	if len(s.orders) < 1 {
+		sql = "order by (select 0) "
+	}
+	offset := s.offset
+	if offset < 0 {
+		offset = 0
+	}
+	sql += fmt.Sprintf("offset %d rows ", offset)
+	if s.limit > -1 {
+		sql += fmt.Sprintf("fetch next %d rows only ", s.limit)
+	}
+
+	return sql
+}
+
+func (g *SqlServerGrammar) unionPart(s *QueryBuilder, sql string) string {
+	return fmt.Sprintf("select * from (%s) as %s ", strings.TrimSpace(sql), g.Wrap("temp_table"))
+}
+
+// lockClause is ignored, locks are compiled to table hints
+func (g *SqlServerGrammar) lockClause(s *QueryBuilder) string {
+	return ""
+}
+
+func (g *SqlServerGrammar) lockHint(s *QueryBuilder) string {
+	hints := []string{"rowlock"}
+	if s.lock == lockUpdate {
+		hints = append(hints, "updlock")
+	} else {
+		hints = append(hints, "holdlock")
+	}
+	switch s.lockModifier {
+	case "skip locked":
+		hints = append(hints, "readpast")
+	case "nowait":
+		hints = append(hints, "nowait")
+	}
+
+	return fmt.Sprintf("with (%s) ", strings.Join(hints, ", "))
+}
+
+func (g *SqlServerGrammar) dateColumn(part, column string) string {
+	switch part {
+	case "date", "time":
+		return fmt.Sprintf("cast(%s as %s)", column, part)
+	}
+
+	return fmt.Sprintf("datepart(%s, %s)", part, column)
+}
+
+func (g *SqlServerGrammar) CompileExists(s *QueryBuilder) string {
+	return fmt.Sprintf("select case when exists(%s) then 1 else 0 end as %s", strings.TrimSpace(g.Compile(s)), g.Wrap("exists"))
+}
+
+// savepointStatement compile "save transaction", savepoints can not be released
+func (g *SqlServerGrammar) savepointStatement(action, name string) string {
+	switch action {
+	case "release":
+		return ""
+	case "rollback":
+		return "rollback transaction " + g.quote(name)
+	}
+
+	return "save transaction " + g.quote(name)
+}
+
+// CompileInsertOrIgnore returns empty sql, since sql server can not ignore
+// duplications without unique columns, use Upsert instead
+func (g *SqlServerGrammar) CompileInsertOrIgnore(table string, rows []map[string]interface{}) (sql string, bindings []interface{}) {
+	return "", nil
+}
+
+// CompileUpsert compile to "merge" statement matching uniqueBy columns,
+// empty sql is returned without uniqueBy
+func (g *SqlServerGrammar) CompileUpsert(table string, rows []map[string]interface{}, uniqueBy []string, update []string) (sql string, bindings []interface{}) {
+	if len(uniqueBy) < 1 {
+		return "", nil
+	}
+	columns := insertColumns(rows)
+	wrapped := strings.Join(g.wrapColumns(columns), ", ")
+	values := g.compileInsertValues(columns, rows, &bindings)
+
+	// aliases are not prefixed
+	target := func(column string) string { return g.quote("target") + "." + g.quote(column) }
+	source := func(column string) string { return g.quote("source") + "." + g.quote(column) }
+
+	var on, sets, sources []string
+	for _, column := range uniqueBy {
+		on = append(on, fmt.Sprintf("%s = %s", target(column), source(column)))
+	}
+	for _, column := range g.upsertColumns(rows, uniqueBy, update) {
+		sets = append(sets, fmt.Sprintf("%s = %s", g.Wrap(column), source(column)))
+	}
+	for _, column := range columns {
+		sources = append(sources, source(column))
+	}
+
+	sql = fmt.Sprintf("merge %s as %s using (values %s) as %s (%s) on %s ", g.WrapTable(table), g.quote("target"),
+		values, g.quote("source"), wrapped, strings.Join(on, " and "))
+	if len(sets) > 0 {
+		sql += "when matched then update set " + strings.Join(sets, ", ") + " "
+	}
+
+	return sql + fmt.Sprintf("when not matched then insert (%s) values (%s);", wrapped, strings.Join(sources, ", ")), bindings
+}
+
+// CompileInsertGetId compile insert with "output inserted" of key
+func (g *SqlServerGrammar) CompileInsertGetId(table string, data map[string]interface{}, key string) (sql string, bindings []interface{}) {
+	rows := []map[string]interface{}{data}
+	columns := insertColumns(rows)
+
+	return fmt.Sprintf("insert into %s (%s) output %s values %s", g.WrapTable(table),
+		strings.Join(g.wrapColumns(columns), ", "), "inserted."+g.quote(key), g.compileInsertValues(columns, rows, &bindings)), bindings
+}
+
+// CompileUpdate compile limit to "top", joins to "from" clause, orders are not supported
+func (g *SqlServerGrammar) CompileUpdate(s *QueryBuilder, values map[string]interface{}) (sql string, bindings []interface{}) {
+	sets, setValues := g.compileSets(values)
+	if len(s.joins) > 0 {
+		sql = fmt.Sprintf("update %s set %s from %s %s", g.WrapTable(g.tableAlias(s.from)), sets,
+			g.WrapTable(s.from), g.compileJoins(s)) + g.CompileWheres(s, true)
+		bindings = append(setValues, s.joinBindings()...)
+
+		return sql, append(bindings, flatBindings(s.bindings)...)
+	}
+	sql = fmt.Sprintf("update %s%s set %s ", g.compileTop(s), g.WrapTable(s.from), sets) + g.CompileWheres(s, true)
+
+	return sql, append(setValues, flatBindings(s.bindings)...)
+}
+
+func (g *SqlServerGrammar) CompileDelete(s *QueryBuilder) (sql string, bindings []interface{}) {
+	if len(s.joins) > 0 {
+		sql = fmt.Sprintf("delete %s from %s %s", g.WrapTable(g.tableAlias(s.from)), g.WrapTable(s.from),
+			g.compileJoins(s)) + g.CompileWheres(s, true)
+
+		return sql, append(s.joinBindings(), flatBindings(s.bindings)...)
+	}
+	sql = fmt.Sprintf("delete %sfrom %s ", g.compileTop(s), g.WrapTable(s.from)) + g.CompileWheres(s, true)
+
+	return sql, flatBindings(s.bindings)
+}
+
+func (g *SqlServerGrammar) compileTop(s *QueryBuilder) string {
+	if s.limit < 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("top (%d) ", s.limit)
+}
+
+// tableAlias returns alias of "table as alias", or the table
+func (g *SqlServerGrammar) tableAlias(table string) string {
+	if alias := strings.Index(table, " as "); alias > -1 {
+		return table[alias+4:]
+	}
+
+	return table
+}
+
+// replacePlaceholders replace "?" by placeholder(n) in order,
+// question marks in quoted strings or identifiers are kept
+func replacePlaceholders(sql string, placeholder func(n int) string) string {
+	var (
+		b     strings.Builder
+		n     int
+		quote byte
+	)
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			n++
+			b.WriteString(placeholder(n))
+			continue
+		}
+		b.WriteByte(c)
+	}
+
+	return b.String()
+}
+
 func RegisterGrammar(name string, g Grammar) {
 	if grammars == nil {
 		grammars = make(map[string]Grammar)
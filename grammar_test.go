@@ -0,0 +1,308 @@
+package database_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/enorith/database"
+)
+
+func postgresBuilder() *database.QueryBuilder {
+	database.WithPostgres()
+	return database.NewBuilder(database.NewConnection("postgres", ""))
+}
+
+func assertSql(t *testing.T, name, sql, expect string) {
+	t.Helper()
+	if sql != expect {
+		t.Fatalf("%s got:\n%s\nexpect:\n%s", name, sql, expect)
+	}
+}
+
+func TestPostgresGrammar_Select(t *testing.T) {
+	b := postgresBuilder().From("users as u").Select("u.id", database.Raw("count(*) as total")).
+		AndWhere("u.name", "=", "tom").WhereIn("u.age", []interface{}{1, 2}, true).
+		WhereRaw("u.note <> '?'").GroupBy("u.id").Having("total", ">", 1).
+		SortDesc("u.id").Take(10).Offset(20).LockForUpdate()
+	sql, e := b.ToSql()
+	if e != nil {
+		t.Fatal(e)
+	}
+	assertSql(t, "select", sql, `select "u"."id", count(*) as total from "users" as "u" `+
+		`where "u"."name" = $1 and "u"."age" in ($2,$3) and u.note <> '?' group by "u"."id" `+
+		`having "total" > $4 order by "u"."id" desc limit 10 offset 20 for update `)
+
+	sql, _ = postgresBuilder().From("users").Offset(20).ToSql()
+	assertSql(t, "offset without limit", sql, `select * from "users" offset 20 `)
+}
+
+func TestPostgresGrammar_PrefixedCte(t *testing.T) {
+	database.WithPostgres()
+	prefixed := func() *database.QueryBuilder {
+		return database.NewBuilder(database.NewConnection("postgres", "").TablePrefix("p_"))
+	}
+	anchor := prefixed().From("categories").Select("id").AndWhere("id", "=", 1)
+	recursive := prefixed().From("categories as c").Select("c.id").
+		Join("tree", "tree.id", "=", "c.parent_id", "inner")
+	sql, _ := prefixed().WithRecursive("tree", []string{"id"}, anchor, recursive).From("tree").ToSql()
+	assertSql(t, "prefixed cte", sql, `with recursive "p_tree" ("id") as (select "id" from "p_categories" where "id" = $1 `+
+		`union all select "p_c"."id" from "p_categories" as "p_c" inner join "p_tree" on "p_tree"."id" = "p_c"."parent_id") `+
+		`select * from "p_tree" `)
+}
+
+func TestPostgresGrammar_Insert(t *testing.T) {
+	g := database.NewPostgresGrammar()
+	rows := []map[string]interface{}{{"email": "a@b.c", "name": "a"}}
+
+	sql, bindings := g.CompileUpsert("users", rows, []string{"email"}, nil)
+	assertSql(t, "upsert", sql, `insert into "users" ("email", "name") values (?, ?) `+
+		`on conflict ("email") do update set "name" = excluded."name"`)
+	if len(bindings) != 2 {
+		t.Fatalf("upsert got %d bindings, expect 2", len(bindings))
+	}
+
+	sql, _ = g.CompileUpsert("users", rows, []string{"email", "name"}, nil)
+	assertSql(t, "upsert nothing to update", sql, `insert into "users" ("email", "name") values (?, ?) `+
+		`on conflict ("email", "name") do nothing`)
+
+	if sql, _ = g.CompileUpsert("users", rows, nil, nil); sql != "" {
+		t.Fatalf("upsert without uniqueBy got %s, expect empty", sql)
+	}
+	_, e := postgresBuilder().From("users").Upsert(rows, nil, nil)
+	if !errors.Is(e, database.ErrUnsupported) {
+		t.Fatalf("upsert without uniqueBy got error %v, expect %v", e, database.ErrUnsupported)
+	}
+
+	sql, _ = g.CompileInsertOrIgnore("users", rows)
+	assertSql(t, "insert or ignore", sql, `insert into "users" ("email", "name") values (?, ?) on conflict do nothing`)
+
+	sql, _ = g.CompileInsertGetId("users", rows[0], "id")
+	assertSql(t, "insert get id", sql, `insert into "users" ("email", "name") values (?, ?) returning "id"`)
+}
+
+func TestPostgresGrammar_UpdateAndDelete(t *testing.T) {
+	g := database.NewPostgresGrammar()
+
+	sql, bindings := g.CompileUpdate(postgresBuilder().From("users").AndWhere("id", "=", 1),
+		map[string]interface{}{"name": "tom"})
+	assertSql(t, "update", sql, `update "users" set "name" = ? where "id" = ? `)
+	if len(bindings) != 2 || bindings[0] != "tom" {
+		t.Fatalf("update got bindings %v", bindings)
+	}
+
+	sql, _ = g.CompileDelete(postgresBuilder().From("users").AndWhere("age", ">", 1).SortAsc("id").Take(1))
+	assertSql(t, "delete", sql, `delete from "users" where "ctid" in `+
+		`(select "users"."ctid" from "users" where "age" > ? order by "id" asc limit 1) `)
+}
+
+func sqlServerBuilder() *database.QueryBuilder {
+	database.WithSqlServer()
+	return database.NewBuilder(database.NewConnection("sqlserver", ""))
+}
+
+func TestSqlServerGrammar_Select(t *testing.T) {
+	b := sqlServerBuilder().From("users as u").Select("u.id", "u.name").
+		Join("posts", "posts.user_id", "=", "u.id", "left").
+		AndWhere("u.name", "=", "tom").WhereBetween("u.age", 1, 9, true).AndWhereNull("u.deleted_at").
+		WhereColumn("u.id", "<>", "posts.id", true).GroupBy("u.id", "u.name").
+		Having("u.id", ">", 0).Take(10)
+	_, e := b.AndWhereNest(func(builder *database.QueryBuilder) {
+		builder.AndWhere("u.a", "=", 1).OrWhere("u.b", "=", 2)
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	_, e = b.AndWhereExists(func(builder *database.QueryBuilder) {
+		builder.From("roles").WhereColumn("roles.user_id", "=", "u.id", true)
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	sql, e := b.ToSql()
+	if e != nil {
+		t.Fatal(e)
+	}
+	assertSql(t, "select top", sql, `select top 10 [u].[id], [u].[name] from [users] as [u] `+
+		`left join [posts] on [posts].[user_id] = [u].[id] where [u].[name] = @p1 and [u].[age] between @p2 and @p3 `+
+		`and [u].[deleted_at] is null  and [u].[id] <> [posts].[id] and ([u].[a] = @p4 or [u].[b] = @p5 ) `+
+		`and exists (select * from [roles] where [roles].[user_id] = [u].[id]) `+
+		`group by [u].[id],[u].[name] having [u].[id] > @p6 `)
+
+	sql, _ = sqlServerBuilder().From("users").Offset(20).Take(10).ToSql()
+	assertSql(t, "offset without orders", sql,
+		`select * from [users] order by (select 0) offset 20 rows fetch next 10 rows only `)
+
+	sql, _ = sqlServerBuilder().From("users").SortDesc("id").Offset(5).ToSql()
+	assertSql(t, "offset", sql, `select * from [users] order by [id] desc offset 5 rows `)
+
+	sql, _ = sqlServerBuilder().From("users").AndWhere("id", "=", 1).LockForUpdate().SkipLocked().ToSql()
+	assertSql(t, "lock", sql, `select * from [users] with (rowlock, updlock, readpast) where [id] = @p1 `)
+}
+
+func TestSqlServerGrammar_UnionAndCte(t *testing.T) {
+	other := sqlServerBuilder().From("admins").Select("id").SortDesc("id").Take(1)
+	sql, _ := sqlServerBuilder().From("users").Select("id").Union(other).Take(5).ToSql()
+	assertSql(t, "union", sql, `select [id] from [users] union select * from `+
+		`(select top 1 [id] from [admins] order by [id] desc) as [temp_table] `+
+		`order by (select 0) offset 0 rows fetch next 5 rows only `)
+
+	anchor := sqlServerBuilder().From("categories").Select("id").AndWhere("id", "=", 1)
+	recursive := sqlServerBuilder().From("categories as c").Select("c.id").
+		Join("tree", "tree.id", "=", "c.parent_id", "inner")
+	sql, _ = sqlServerBuilder().WithRecursive("tree", []string{"id"}, anchor, recursive).From("tree").ToSql()
+	assertSql(t, "cte", sql, `with [tree] ([id]) as (select [id] from [categories] where [id] = @p1 `+
+		`union all select [c].[id] from [categories] as [c] inner join [tree] on [tree].[id] = [c].[parent_id]) `+
+		`select * from [tree] `)
+}
+
+func TestSqlServerGrammar_Statements(t *testing.T) {
+	g := database.NewSqlServerGrammar()
+	b := sqlServerBuilder().From("users").AndWhere("id", "=", 1)
+
+	assertSql(t, "exists", g.CompileExists(b),
+		`select case when exists(select * from [users] where [id] = ?) then 1 else 0 end as [exists]`)
+	assertSql(t, "count", g.CompileCount(b), `select count(*) as [aggregate] from [users] where [id] = ? `)
+
+	rows := []map[string]interface{}{{"email": "a@b.c", "name": "a"}, {"email": "d@e.f", "name": "d"}}
+	sql, bindings := g.CompileInsertMany("users", rows)
+	assertSql(t, "insert", sql, `insert into [users] ([email], [name]) values (?, ?), (?, ?)`)
+	if len(bindings) != 4 {
+		t.Fatalf("insert got %d bindings, expect 4", len(bindings))
+	}
+
+	sql, _ = g.CompileInsertGetId("users", rows[0], "id")
+	assertSql(t, "insert get id", sql, `insert into [users] ([email], [name]) output inserted.[id] values (?, ?)`)
+
+	sql, _ = g.CompileUpsert("users", rows, []string{"email"}, nil)
+	assertSql(t, "upsert", sql, `merge [users] as [target] using (values (?, ?), (?, ?)) as [source] ([email], [name]) `+
+		`on [target].[email] = [source].[email] when matched then update set [name] = [source].[name] `+
+		`when not matched then insert ([email], [name]) values ([source].[email], [source].[name]);`)
+	if sql, _ = g.CompileUpsert("users", rows, nil, nil); sql != "" {
+		t.Fatalf("upsert without uniqueBy got %s, expect empty", sql)
+	}
+
+	sql, _ = g.CompileUpdate(sqlServerBuilder().From("users").AndWhere("age", ">", 1).Take(2),
+		map[string]interface{}{"name": "tom"})
+	assertSql(t, "update", sql, `update top (2) [users] set [name] = ? where [age] > ? `)
+
+	sql, _ = g.CompileDelete(sqlServerBuilder().From("users as u").Join("posts", "posts.user_id", "=", "u.id", "inner").
+		AndWhere("posts.id", "=", 1))
+	assertSql(t, "delete", sql, `delete [u] from [users] as [u] inner join [posts] on [posts].[user_id] = [u].[id] where [posts].[id] = ? `)
+
+	_, e := sqlServerBuilder().From("users").InsertOrIgnore(rows)
+	if e != database.ErrUnsupported {
+		t.Fatalf("insert or ignore got error %v, expect %v", e, database.ErrUnsupported)
+	}
+}
+
+func sqliteBuilder() *database.QueryBuilder {
+	database.WithSqlite()
+	return database.NewBuilder(database.NewConnection("sqlite3", ""))
+}
+
+func TestSqliteGrammar_Select(t *testing.T) {
+	sql, _ := sqliteBuilder().From("users").SortAsc("id").Offset(10).ToSql()
+	assertSql(t, "offset", sql, `select * from "users" order by "id" asc limit -1 offset 10 `)
+
+	sql, _ = sqliteBuilder().From("users").AndWhereDate("created_at", "=", "2020-01-02").
+		AndWhereYear("created_at", ">", 2019).ToSql()
+	assertSql(t, "date", sql, `select * from "users" where strftime('%Y-%m-%d', "created_at") = ? `+
+		`and cast(strftime('%Y', "created_at") as integer) > ? `)
+
+	other := sqliteBuilder().From("admins").SortDesc("id").Take(1)
+	sql, _ = sqliteBuilder().From("users").Union(other).ToSql()
+	assertSql(t, "union", sql, `select * from "users" union select * from (select * from "admins" order by "id" desc limit 1) `)
+
+	g := database.NewSqliteGrammar()
+	assertSql(t, "exists", g.CompileExists(sqliteBuilder().From("users")),
+		`select exists(select * from "users" ) as "exists"`)
+}
+
+func TestSqliteGrammar_Statements(t *testing.T) {
+	g := database.NewSqliteGrammar()
+	rows := []map[string]interface{}{{"name": "a", "active": true}}
+
+	sql, _ := g.CompileUpsert("users", rows, []string{"name"}, nil)
+	assertSql(t, "upsert", sql, `insert into "users" ("active", "name") values (?, ?) `+
+		`on conflict ("name") do update set "active" = excluded."active"`)
+	sql, _ = g.CompileUpsert("users", rows, []string{"name", "active"}, nil)
+	assertSql(t, "upsert nothing to update", sql, `insert into "users" ("active", "name") values (?, ?) `+
+		`on conflict ("name", "active") do nothing`)
+	if sql, _ = g.CompileUpsert("users", rows, nil, nil); sql != "" {
+		t.Fatalf("upsert without uniqueBy got %s, expect empty", sql)
+	}
+
+	sql, _ = g.CompileReplace("users", rows)
+	assertSql(t, "replace", sql, `insert or replace into "users" ("active", "name") values (?, ?)`)
+
+	sql, _ = g.CompileUpdate(sqliteBuilder().From("users").AndWhere("active", "=", false).Take(1),
+		map[string]interface{}{"name": "b"})
+	assertSql(t, "update", sql, `update "users" set "name" = ? where "rowid" in `+
+		`(select "users"."rowid" from "users" where "active" = ? limit 1) `)
+}
+
+func TestMysqlGrammar_Upsert(t *testing.T) {
+	g := database.NewMysqlGrammar()
+	rows := []map[string]interface{}{{"email": "a@b.c", "name": "a"}}
+
+	sql, _ := g.CompileUpsert("users", rows, []string{"email"}, nil)
+	assertSql(t, "upsert", sql, "insert into `users` (`email`, `name`) values (?, ?) "+
+		"on duplicate key update `name` = values(`name`)")
+
+	sql, _ = g.CompileUpsert("users", rows, []string{"email", "name"}, nil)
+	assertSql(t, "upsert nothing to update", sql, "insert ignore into `users` (`email`, `name`) values (?, ?)")
+}
+
+func TestQueryBuilder_BindingOrder(t *testing.T) {
+	database.WithMysql()
+	mysqlBuilder := func() *database.QueryBuilder {
+		return database.NewBuilder(database.NewConnection("mysql", ""))
+	}
+	joinActive := func(c *database.JoinClause) {
+		c.AndOn("u.id", "=", "o.user_id").AndWhere("u.active", "=", 2)
+	}
+
+	// wheres are added before the subquery and join
+	q := mysqlBuilder().AndWhere("o.status", "=", 3)
+	q, _ = q.FromSub(mysqlBuilder().From("orders").AndWhere("amount", ">", 1), "o")
+	q = q.JoinWith("inner", "users as u", joinActive)
+	sql, _ := q.ToSql()
+	assertSql(t, "select", sql, "select * from (select * from `orders` where `amount` > ? ) as `o` "+
+		"inner join `users` as `u` on `u`.`id` = `o`.`user_id` and `u`.`active` = ? where `o`.`status` = ? ")
+	if bindings := fmt.Sprint(q.FlatBindings()); bindings != "[1 2 3]" {
+		t.Fatalf("select got bindings %s, expect [1 2 3]", bindings)
+	}
+
+	update := func(q *database.QueryBuilder) (string, []interface{}) {
+		conn, _ := q.GetConnection()
+		g, _ := conn.GetGrammar()
+		return g.CompileUpdate(q.JoinWith("inner", "users as u", joinActive), map[string]interface{}{"status": 1})
+	}
+	sql, bindings := update(mysqlBuilder().AndWhere("o.status", "=", 3).From("orders as o"))
+	assertSql(t, "update", sql, "update `orders` as `o` inner join `users` as `u` on `u`.`id` = `o`.`user_id` "+
+		"and `u`.`active` = ? set `status` = ? where `o`.`status` = ? ")
+	if fmt.Sprint(bindings) != "[2 1 3]" {
+		t.Fatalf("update got bindings %v, expect [2 1 3]", bindings)
+	}
+
+	sql, bindings = update(postgresBuilder().AndWhere("o.status", "=", 3).From("orders as o"))
+	assertSql(t, "update by key", sql, `update "orders" as "o" set "status" = ? where "ctid" in `+
+		`(select "o"."ctid" from "orders" as "o" inner join "users" as "u" on "u"."id" = "o"."user_id" `+
+		`and "u"."active" = ? where "o"."status" = ?) `)
+	if fmt.Sprint(bindings) != "[1 2 3]" {
+		t.Fatalf("update by key got bindings %v, expect [1 2 3]", bindings)
+	}
+}
+
+func TestSqlGrammar_Default(t *testing.T) {
+	database.RegisterGrammar("default", &database.SqlGrammar{})
+	b := database.NewBuilder(database.NewConnection("default", "").TablePrefix("p_"))
+	sql, _ := b.From("users").AndWhere("id", "=", 1).ToSql()
+	assertSql(t, "select", sql, "select * from `p_users` where `id` = ? ")
+
+	_, e := b.From("users").InsertOrIgnore([]map[string]interface{}{{"name": "a"}})
+	if !errors.Is(e, database.ErrUnsupported) {
+		t.Fatalf("insert or ignore got error %v, expect %v", e, database.ErrUnsupported)
+	}
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+)
+
+// QueryInfo is the query passing through interceptors, interceptors
+// may change it before calling next
+type QueryInfo struct {
+	// Type is one of "select", "exec", "begin", "commit" and "rollback"
+	Type     string
+	Sql      string
+	Bindings []interface{}
+	// Connection running the query
+	Connection *Connection
+}
+
+// Result of query, Rows is set by select, Result by exec and Tx by begin
+type Result struct {
+	Rows   *Rows
+	Result sql.Result
+	Tx     *sql.Tx
+}
+
+type InterceptHandler func(ctx context.Context, info QueryInfo) (Result, error)
+
+// Interceptor wraps queries of connection, call next to run the query
+type Interceptor func(ctx context.Context, info QueryInfo, next InterceptHandler) (Result, error)
+
+// interceptors run inside of the ones of connections
+var interceptors = []Interceptor{logQuery, dispatchEvent}
+
+// Use append interceptors wrapping select, exec and transactions of the connection,
+// the first one is the outermost. Clones and transactions of the connection share
+// interceptors used before cloning
+func (c *Connection) Use(interceptors ...Interceptor) *Connection {
+	c.interceptors = append(c.interceptors[:len(c.interceptors):len(c.interceptors)], interceptors...)
+	return c
+}
+
+func (c *Connection) intercept(ctx context.Context, info QueryInfo, handler InterceptHandler) (Result, error) {
+	info.Connection = c
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		handler = wrapHandler(interceptors[i], handler)
+	}
+	for i := len(c.interceptors) - 1; i >= 0; i-- {
+		handler = wrapHandler(c.interceptors[i], handler)
+	}
+
+	return handler(ctx, info)
+}
+
+func wrapHandler(interceptor Interceptor, next InterceptHandler) InterceptHandler {
+	return func(ctx context.Context, info QueryInfo) (Result, error) {
+		return interceptor(ctx, info, next)
+	}
+}
@@ -0,0 +1,54 @@
+//go:build cgo
+// +build cgo
+
+package database_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/enorith/database"
+)
+
+func TestConnection_Use(t *testing.T) {
+	var types []string
+	errRejected := errors.New("delete is rejected")
+	conn := sqliteConnection(t).EnableQueryLog()
+	conn.Use(func(ctx context.Context, info database.QueryInfo, next database.InterceptHandler) (database.Result, error) {
+		types = append(types, info.Type)
+		return next(ctx, info)
+	}, func(ctx context.Context, info database.QueryInfo, next database.InterceptHandler) (database.Result, error) {
+		if strings.HasPrefix(info.Sql, "delete") {
+			return database.Result{}, errRejected
+		}
+		if info.Type == "select" || info.Type == "exec" {
+			info.Sql = "/* app */ " + info.Sql
+		}
+		return next(ctx, info)
+	})
+
+	e := conn.Transaction(func(tx *database.Connection) error {
+		_, err := database.NewBuilder(tx).From("users").Insert([]map[string]interface{}{{"name": "tom"}})
+		return err
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if n, _ := database.NewBuilder(conn).From("users").CountOrError(); n != 1 {
+		t.Errorf("got %d users, expect 1", n)
+	}
+	if _, e = database.NewBuilder(conn).From("users").Delete(); e != errRejected {
+		t.Errorf("delete got error %v, expect rejected", e)
+	}
+
+	expect := []string{"begin", "exec", "commit", "select", "exec"}
+	if strings.Join(types, ",") != strings.Join(expect, ",") {
+		t.Errorf("intercepted %v, expect %v", types, expect)
+	}
+	logs := conn.GetQueryLog()
+	if len(logs) != 4 || !strings.HasPrefix(logs[1].Sql, "/* app */ insert") {
+		t.Errorf("query log should record the intercepted sql, got %v", logs)
+	}
+}
@@ -2,6 +2,7 @@ package database
 
 import (
 	"fmt"
+	"math/rand"
 	"sync"
 
 	"github.com/enorith/cache"
@@ -69,6 +70,9 @@ func (m *Manager) GetConnection(name ...string) (*Connection, error) {
 	if e != nil {
 		return nil, fmt.Errorf("register connection error: %v", e)
 	}
+	if c.name == "" {
+		c.name = m.connectionName
+	}
 
 	m.setConnection(m.connectionName, c)
 
@@ -106,15 +110,27 @@ func (m *Manager) setConnection(name string, connection *Connection) {
 func WithDefaultDrivers() {
 	WithMysql()
 	WithSqlite()
+	WithPostgres()
+	WithSqlServer()
 }
 
 func WithMysql() {
-	RegisterGrammar("mysql", &MysqlGrammar{})
+	RegisterGrammar("mysql", NewMysqlGrammar())
 }
 
 func WithSqlite() {
-	RegisterGrammar("sqlite", &SqliteGrammar{})
-	RegisterGrammar("sqlite3", &SqliteGrammar{})
+	RegisterGrammar("sqlite", NewSqliteGrammar())
+	RegisterGrammar("sqlite3", NewSqliteGrammar())
+}
+
+func WithPostgres() {
+	RegisterGrammar("postgres", NewPostgresGrammar())
+	RegisterGrammar("pgx", NewPostgresGrammar())
+}
+
+func WithSqlServer() {
+	RegisterGrammar("sqlserver", NewSqlServerGrammar())
+	RegisterGrammar("mssql", NewSqlServerGrammar())
 }
 
 func NewManager() *Manager {
@@ -125,6 +141,51 @@ func NewManager() *Manager {
 	}
 }
 
+// NewManagerFromConfig returns manager registering every connection of config,
+// using config's default connection
+func NewManagerFromConfig(config Config) (*Manager, error) {
+	m := NewManager()
+	for name, c := range config.Connections {
+		writes, err := c.GetWriteDsns()
+		if err != nil {
+			return nil, fmt.Errorf("connection [%s] %v", name, err)
+		}
+		reads, err := c.GetReadDsns()
+		if err != nil {
+			return nil, fmt.Errorf("connection [%s] %v", name, err)
+		}
+		options := []ConnectionOption{WithPool(c.Pool), WithReadDsn(reads...)}
+		if c.Sticky {
+			options = append(options, WithSticky())
+		}
+		driver, prefix := c.Driver, c.Prefix
+		m.Register(name, func() (*Connection, error) {
+			dsn := writes[rand.Intn(len(writes))]
+			return NewConnection(driver, dsn, options...).TablePrefix(prefix), nil
+		})
+	}
+
+	using := config.Default
+	if using == "" {
+		using = DefaultConnection
+	}
+	if _, ok := config.Connections[using]; !ok {
+		return nil, fmt.Errorf("default connection [%s] is not configured", using)
+	}
+
+	return m.Using(using), nil
+}
+
+// NewManagerFromYaml load yaml config file and returns manager of it
+func NewManagerFromYaml(path string) (*Manager, error) {
+	config, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewManagerFromConfig(config)
+}
+
 func init() {
 	DefaultManager = NewManager()
 }
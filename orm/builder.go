@@ -47,167 +47,121 @@ type Builder struct {
 }
 
 func (b *Builder) From(table string) *Builder {
-	b.QueryBuilder.From(table)
-
-	return b
+	return &Builder{b.QueryBuilder.From(table)}
 }
 
-func (b *Builder) Where(column, operator string, value interface{}, and bool) *Builder {
-	b.QueryBuilder.Where(column, operator, value, and)
-	return b
+func (b *Builder) Where(column interface{}, operator string, value interface{}, and bool) *Builder {
+	return &Builder{b.QueryBuilder.Where(column, operator, value, and)}
 }
 
-func (b *Builder) WhereNull(column string, and bool) *Builder {
-	b.QueryBuilder.WhereNull(column, and)
-
-	return b
+func (b *Builder) WhereNull(column interface{}, and bool) *Builder {
+	return &Builder{b.QueryBuilder.WhereNull(column, and)}
 }
 
-func (b *Builder) AndWhereNull(column string) *Builder {
-	b.QueryBuilder.AndWhereNull(column)
-	return b
+func (b *Builder) AndWhereNull(column interface{}) *Builder {
+	return &Builder{b.QueryBuilder.AndWhereNull(column)}
 }
 
-func (b *Builder) AndWhereNotNull(column string) *Builder {
-	b.QueryBuilder.AndWhereNotNull(column)
-
-	return b
+func (b *Builder) AndWhereNotNull(column interface{}) *Builder {
+	return &Builder{b.QueryBuilder.AndWhereNotNull(column)}
 }
 
 func (b *Builder) WhereNest(and bool, handler database.QueryHandler) (*Builder, error) {
-	_, e := b.QueryBuilder.WhereNest(and, handler)
+	q, e := b.QueryBuilder.WhereNest(and, handler)
 
-	return b, e
+	return &Builder{q}, e
 }
 
 func (b *Builder) AndWhereNest(handler database.QueryHandler) (*Builder, error) {
-	_, e := b.QueryBuilder.AndWhereNest(handler)
+	q, e := b.QueryBuilder.AndWhereNest(handler)
 
-	return b, e
+	return &Builder{q}, e
 }
 
-func (b *Builder) WhereIn(column string, value []interface{}, and bool) *Builder {
-	b.QueryBuilder.WhereIn(column, value, and)
-
-	return b
+func (b *Builder) WhereIn(column interface{}, value []interface{}, and bool) *Builder {
+	return &Builder{b.QueryBuilder.WhereIn(column, value, and)}
 }
 
-func (b *Builder) WhereBetween(column string, one interface{}, two interface{}, and bool) *Builder {
-	b.QueryBuilder.WhereBetween(column, one, two, and)
-
-	return b
+func (b *Builder) WhereBetween(column interface{}, one interface{}, two interface{}, and bool) *Builder {
+	return &Builder{b.QueryBuilder.WhereBetween(column, one, two, and)}
 }
 
 func (b *Builder) WhereSub(from, column, operator string, and bool, handler database.QueryHandler) *Builder {
-	b.QueryBuilder.WhereSub(from, column, operator, and, handler)
-
-	return b
+	return &Builder{b.QueryBuilder.WhereSub(from, column, operator, and, handler)}
 }
 
 func (b *Builder) AndWhereSub(from, column, operator string, handler database.QueryHandler) *Builder {
-	b.QueryBuilder.AndWhereSub(from, column, operator, handler)
-
-	return b
+	return &Builder{b.QueryBuilder.AndWhereSub(from, column, operator, handler)}
 }
 
-func (b *Builder) AndWhere(column string, operator string, value interface{}) *Builder {
-	b.QueryBuilder.AndWhere(column, operator, value)
-
-	return b
+func (b *Builder) AndWhere(column interface{}, operator string, value interface{}) *Builder {
+	return &Builder{b.QueryBuilder.AndWhere(column, operator, value)}
 }
 
-func (b *Builder) OrWhere(column string, operator string, value interface{}) *Builder {
-	b.QueryBuilder.OrWhere(column, operator, value)
-
-	return b
+func (b *Builder) OrWhere(column interface{}, operator string, value interface{}) *Builder {
+	return &Builder{b.QueryBuilder.OrWhere(column, operator, value)}
 }
 
 func (b *Builder) FromSub(builder *Builder, as string) (*Builder, error) {
 
-	_, e := b.QueryBuilder.FromSub(builder.QueryBuilder, as)
+	q, e := b.QueryBuilder.FromSub(builder.QueryBuilder, as)
 	if e != nil {
 		return nil, e
 	}
 
-	return b, nil
+	return &Builder{q}, nil
 }
 
-func (b *Builder) Sort(by string, direction string) *Builder {
-	b.QueryBuilder.Sort(by, direction)
-
-	return b
+func (b *Builder) Sort(by interface{}, direction string) *Builder {
+	return &Builder{b.QueryBuilder.Sort(by, direction)}
 }
 
-func (b *Builder) SortDesc(by string) *Builder {
-	b.QueryBuilder.SortDesc(by)
-
-	return b
+func (b *Builder) SortDesc(by interface{}) *Builder {
+	return &Builder{b.QueryBuilder.SortDesc(by)}
 }
 
-func (b *Builder) SortAsc(by string) *Builder {
-	b.QueryBuilder.SortAsc(by)
-
-	return b
+func (b *Builder) SortAsc(by interface{}) *Builder {
+	return &Builder{b.QueryBuilder.SortAsc(by)}
 }
 
-func (b *Builder) Select(columns ...string) *Builder {
-	b.QueryBuilder.Select(columns...)
-
-	return b
+func (b *Builder) Select(columns ...interface{}) *Builder {
+	return &Builder{b.QueryBuilder.Select(columns...)}
 }
 
 func (b *Builder) Join(table, first, operator, second, category string) *Builder {
-	b.QueryBuilder.Join(table, first, operator, second, category)
-
-	return b
+	return &Builder{b.QueryBuilder.Join(table, first, operator, second, category)}
 }
 
 func (b *Builder) LeftJoin(table, first, operator, second string) *Builder {
-	b.QueryBuilder.LeftJoin(table, first, operator, second)
-
-	return b
+	return &Builder{b.QueryBuilder.LeftJoin(table, first, operator, second)}
 }
 
 func (b *Builder) RightJoin(table, first, operator, second string) *Builder {
-	b.QueryBuilder.RightJoin(table, first, operator, second)
-
-	return b
+	return &Builder{b.QueryBuilder.RightJoin(table, first, operator, second)}
 }
 
 func (b *Builder) InnerJoin(table, first, operator, second string) *Builder {
-	b.QueryBuilder.InnerJoin(table, first, operator, second)
-
-	return b
+	return &Builder{b.QueryBuilder.InnerJoin(table, first, operator, second)}
 }
 
 func (b *Builder) JoinWith(category, table string, handler database.JoinHandler) *Builder {
-	b.QueryBuilder.JoinWith(category, table, handler)
-
-	return b
+	return &Builder{b.QueryBuilder.JoinWith(category, table, handler)}
 }
 
 func (b *Builder) Take(limit int) *Builder {
-	b.QueryBuilder.Take(limit)
-
-	return b
+	return &Builder{b.QueryBuilder.Take(limit)}
 }
 
 func (b *Builder) Offset(offset int) *Builder {
-	b.QueryBuilder.Offset(offset)
-
-	return b
+	return &Builder{b.QueryBuilder.Offset(offset)}
 }
 
-func (b *Builder) GroupBy(columns ...string) *Builder {
-	b.QueryBuilder.GroupBy(columns...)
-
-	return b
+func (b *Builder) GroupBy(columns ...interface{}) *Builder {
+	return &Builder{b.QueryBuilder.GroupBy(columns...)}
 }
 
 func (b *Builder) ForPage(page, perPage int) *Builder {
-	b.QueryBuilder.ForPage(page, perPage)
-
-	return b
+	return &Builder{b.QueryBuilder.ForPage(page, perPage)}
 }
 
 func (b *Builder) Using(connection string) error {
@@ -219,7 +173,7 @@ func (b *Builder) Using(connection string) error {
 	return nil
 }
 
-func (b *Builder) Get(columns ...string) (*database.Collection, error) {
+func (b *Builder) Get(columns ...interface{}) (*database.Collection, error) {
 	return b.QueryBuilder.Get(columns...)
 }
 
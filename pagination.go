@@ -1,7 +1,11 @@
 package database
 
 import (
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"math"
+	"strings"
 )
 
 type lengthAwareMeta struct {
@@ -81,7 +85,7 @@ func (p *LengthAwarePaginator) Items() (*Collection, error) {
 		return p.items, nil
 	}
 	var err error
-	p.items, err = p.builder.ForPage(p.page, p.perPage).Get()
+	p.items, err = p.builder.Clone().ForPage(p.page, p.perPage).Get()
 	return p.items, err
 }
 
@@ -93,3 +97,248 @@ func (p *LengthAwarePaginator) lastIndex() int64 {
 
 	return p.firstIndex() + int64(items.Len()-1)
 }
+
+type simpleMeta struct {
+	CurrentPage int   `json:"current_page"`
+	PerPage     int   `json:"per_page"`
+	From        int64 `json:"from"`
+	To          int64 `json:"to"`
+	HasMore     bool  `json:"has_more"`
+}
+
+type SimpleResult struct {
+	Data  *Collection `json:"data"`
+	Meta  simpleMeta  `json:"meta"`
+	Valid bool        `json:"-"`
+}
+
+// SimplePaginator fetch one more item to determine whether more pages exist, without counting
+type SimplePaginator struct {
+	builder *QueryBuilder
+	page    int
+	perPage int
+	hasMore bool
+	items   *Collection
+}
+
+func (p *SimplePaginator) MarshalJSON() ([]byte, error) {
+	result, err := p.ToResult()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(result)
+}
+
+func (p *SimplePaginator) ToResult() (SimpleResult, error) {
+	result, err := p.Items()
+
+	if err != nil {
+		return SimpleResult{}, err
+	}
+
+	from := int64((p.page-1)*p.perPage + 1)
+	meta := simpleMeta{
+		CurrentPage: p.page,
+		PerPage:     p.perPage,
+		From:        from,
+		To:          from + int64(result.Len()-1),
+		HasMore:     p.hasMore,
+	}
+
+	return SimpleResult{
+		Data:  result,
+		Meta:  meta,
+		Valid: true,
+	}, nil
+}
+
+func (p *SimplePaginator) HasMore() (bool, error) {
+	_, err := p.Items()
+
+	return p.hasMore, err
+}
+
+func (p *SimplePaginator) Items() (*Collection, error) {
+	if p.items != nil {
+		return p.items, nil
+	}
+
+	coll, err := p.builder.Clone().Offset((p.page - 1) * p.perPage).Take(p.perPage + 1).Get()
+	if err != nil {
+		return nil, err
+	}
+	items := coll.GetItems()
+	if len(items) > p.perPage {
+		p.hasMore = true
+		items = items[:p.perPage]
+	}
+	p.items = &Collection{items: items, loaded: true}
+
+	return p.items, nil
+}
+
+type cursorMeta struct {
+	PerPage    int    `json:"per_page"`
+	Cursor     string `json:"cursor"`
+	NextCursor string `json:"next_cursor"`
+	HasMore    bool   `json:"has_more"`
+}
+
+type CursorResult struct {
+	Data  *Collection `json:"data"`
+	Meta  cursorMeta  `json:"meta"`
+	Valid bool        `json:"-"`
+}
+
+// CursorPaginator paginate by keyset of query orders, the cursor is
+// an opaque string of order values of the last item
+type CursorPaginator struct {
+	builder    *QueryBuilder
+	perPage    int
+	cursor     string
+	nextCursor string
+	items      *Collection
+}
+
+func (p *CursorPaginator) MarshalJSON() ([]byte, error) {
+	result, err := p.ToResult()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(result)
+}
+
+func (p *CursorPaginator) ToResult() (CursorResult, error) {
+	result, err := p.Items()
+
+	if err != nil {
+		return CursorResult{}, err
+	}
+
+	meta := cursorMeta{
+		PerPage:    p.perPage,
+		Cursor:     p.cursor,
+		NextCursor: p.nextCursor,
+		HasMore:    p.nextCursor != "",
+	}
+
+	return CursorResult{
+		Data:  result,
+		Meta:  meta,
+		Valid: true,
+	}, nil
+}
+
+// NextCursor returns cursor of next page, empty if no more pages
+func (p *CursorPaginator) NextCursor() (string, error) {
+	_, err := p.Items()
+
+	return p.nextCursor, err
+}
+
+func (p *CursorPaginator) Items() (*Collection, error) {
+	if p.items != nil {
+		return p.items, nil
+	}
+
+	orders := p.builder.orders
+	if len(orders) < 1 {
+		return nil, errors.New("cursor paginate: query should be sorted")
+	}
+	for _, o := range orders {
+		if _, ok := o.column.(Expression); ok {
+			return nil, errors.New("cursor paginate: can not paginate by raw orders")
+		}
+	}
+	builder := p.builder.Clone()
+
+	if p.cursor != "" {
+		values, err := decodeCursor(p.cursor, len(orders))
+		if err != nil {
+			return nil, err
+		}
+		builder, err = builder.AndWhereNest(func(builder *QueryBuilder) {
+			for i := range orders {
+				builder.WhereNest(false, func(builder *QueryBuilder) {
+					for j := 0; j < i; j++ {
+						builder.AndWhere(orders[j].column, "=", values[j])
+					}
+					operator := ">"
+					if strings.ToLower(orders[i].direction) == "desc" {
+						operator = "<"
+					}
+					builder.AndWhere(orders[i].column, operator, values[i])
+				})
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	coll, err := builder.Take(p.perPage + 1).Get()
+	if err != nil {
+		return nil, err
+	}
+	items := coll.GetItems()
+	if len(items) > p.perPage {
+		items = items[:p.perPage]
+		p.nextCursor, err = encodeCursor(items[len(items)-1], orders)
+		if err != nil {
+			return nil, err
+		}
+	}
+	p.items = &Collection{items: items, loaded: true}
+
+	return p.items, nil
+}
+
+func encodeCursor(item *CollectionItem, orders []order) (string, error) {
+	values := make([]interface{}, len(orders))
+	for i, o := range orders {
+		key := columnName(o.column)
+		if dot := strings.LastIndexByte(key, '.'); dot > -1 {
+			key = key[dot+1:]
+		}
+		v, err := item.GetValue(key)
+		if err != nil {
+			return "", err
+		}
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+		values[i] = v
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(cursor string, length int) ([]interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("cursor paginate: invalid cursor %v", err)
+	}
+	var values []interface{}
+	if err = json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("cursor paginate: invalid cursor %v", err)
+	}
+	if len(values) != length {
+		return nil, errors.New("cursor paginate: cursor does not match query orders")
+	}
+	for i, v := range values {
+		// json numbers are decoded as float64, restore integers
+		if f, ok := v.(float64); ok && f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+			values[i] = int64(f)
+		}
+	}
+
+	return values, nil
+}
@@ -0,0 +1,146 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultQueryLogSize is max entries kept by query log if size is not given
+const DefaultQueryLogSize = 1000
+
+// QueryLog is an entry of connection's query log
+type QueryLog struct {
+	// Type is one of "select", "exec", "begin", "commit" and "rollback"
+	Type     string
+	Sql      string
+	Bindings []interface{}
+	Duration time.Duration
+	// Rows is affected rows of exec, or count of rows read from select
+	// once closed, -1 if unknown
+	Rows int64
+	Err  error
+	// id is sequence of the entry in log
+	id int64
+}
+
+type queryLog struct {
+	m       sync.Mutex
+	size    int
+	entries []QueryLog
+	nextId  int64
+}
+
+// append entry to log, returns id of the entry
+func (l *queryLog) append(entry QueryLog) int64 {
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.nextId++
+	entry.id = l.nextId
+	if len(l.entries) >= l.size {
+		n := copy(l.entries, l.entries[len(l.entries)-l.size+1:])
+		for i := n; i < len(l.entries); i++ {
+			l.entries[i] = QueryLog{}
+		}
+		l.entries = l.entries[:n]
+	}
+	l.entries = append(l.entries, entry)
+
+	return entry.id
+}
+
+// countRows set rows of entry, it's ignored if the entry is dropped
+func (l *queryLog) countRows(id, n int64) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	for i := len(l.entries) - 1; i >= 0 && l.entries[i].id >= id; i-- {
+		if l.entries[i].id == id {
+			l.entries[i].Rows = n
+			return
+		}
+	}
+}
+
+// EnableQueryLog record queries of the connection, at most size entries are
+// kept and older ones are dropped. Clones and transactions of the connection
+// share the log
+func (c *Connection) EnableQueryLog(size ...int) *Connection {
+	s := DefaultQueryLogSize
+	if len(size) > 0 && size[0] > 0 {
+		s = size[0]
+	}
+	if c.queryLog == nil {
+		c.queryLog = &queryLog{size: s}
+	} else {
+		c.queryLog.m.Lock()
+		c.queryLog.size = s
+		c.queryLog.m.Unlock()
+	}
+
+	return c
+}
+
+// DisableQueryLog stop recording queries and drop the log
+func (c *Connection) DisableQueryLog() *Connection {
+	c.queryLog = nil
+	return c
+}
+
+func (c *Connection) QueryLogEnabled() bool {
+	return c.queryLog != nil
+}
+
+// GetQueryLog returns copy of recorded queries, from oldest to latest
+func (c *Connection) GetQueryLog() []QueryLog {
+	if c.queryLog == nil {
+		return nil
+	}
+	c.queryLog.m.Lock()
+	defer c.queryLog.m.Unlock()
+	entries := make([]QueryLog, len(c.queryLog.entries))
+	copy(entries, c.queryLog.entries)
+
+	return entries
+}
+
+// FlushQueryLog clear recorded queries
+func (c *Connection) FlushQueryLog() {
+	if c.queryLog == nil {
+		return
+	}
+	c.queryLog.m.Lock()
+	c.queryLog.entries = nil
+	c.queryLog.m.Unlock()
+}
+
+// logQuery record queries to query log of the connection if enabled
+func logQuery(ctx context.Context, info QueryInfo, next InterceptHandler) (Result, error) {
+	log := info.Connection.queryLog
+	if log == nil {
+		return next(ctx, info)
+	}
+	startAt := time.Now()
+	result, err := next(ctx, info)
+	entry := QueryLog{
+		Type:     info.Type,
+		Sql:      info.Sql,
+		Bindings: info.Bindings,
+		Duration: time.Since(startAt),
+		Rows:     -1,
+		Err:      err,
+	}
+	if result.Result != nil && err == nil {
+		if n, e := result.Result.RowsAffected(); e == nil {
+			entry.Rows = n
+		}
+	}
+	id := log.append(entry)
+	// rows refer to the log, never the other way around
+	if result.Rows != nil {
+		result.Rows.closed = func(read int64) {
+			log.countRows(id, read)
+		}
+	}
+
+	return result, err
+}
@@ -0,0 +1,70 @@
+//go:build cgo
+// +build cgo
+
+package database_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/enorith/database"
+)
+
+func TestConnection_QueryLog(t *testing.T) {
+	conn := sqliteConnection(t).EnableQueryLog()
+	conn.FlushQueryLog()
+
+	e := conn.Transaction(func(tx *database.Connection) error {
+		_, err := database.NewBuilder(tx).From("users").Insert([]map[string]interface{}{{"name": "tom"}, {"name": "bob"}})
+		return err
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if _, e = database.NewBuilder(conn).From("users").Get(); e != nil {
+		t.Fatal(e)
+	}
+
+	logs := conn.GetQueryLog()
+	types := []string{"begin", "exec", "commit", "select"}
+	if len(logs) != len(types) {
+		t.Fatalf("got %d query logs, expect %d", len(logs), len(types))
+	}
+	for i, typ := range types {
+		if logs[i].Type != typ {
+			t.Errorf("query log %d got type %s, expect %s", i, logs[i].Type, typ)
+		}
+	}
+	if logs[1].Rows != 2 || len(logs[1].Bindings) != 2 {
+		t.Errorf("insert log got %d rows and %d bindings, expect 2 and 2", logs[1].Rows, len(logs[1].Bindings))
+	}
+	if logs[3].Rows != 2 || !strings.HasPrefix(logs[3].Sql, `select * from "users"`) {
+		t.Errorf("select log got %d rows of %q", logs[3].Rows, logs[3].Sql)
+	}
+
+	conn.EnableQueryLog(2)
+	conn.Exec("select 1")
+	if logs = conn.GetQueryLog(); len(logs) != 2 || logs[1].Sql != "select 1" {
+		t.Errorf("bounded query log got %d entries", len(logs))
+	}
+	if _, e = conn.Exec("insert into missing values (1)"); e == nil || conn.GetQueryLog()[1].Err == nil {
+		t.Error("failed query should be logged with error")
+	}
+
+	conn.FlushQueryLog()
+	if logs = conn.GetQueryLog(); len(logs) != 0 {
+		t.Errorf("flushed query log got %d entries", len(logs))
+	}
+}
+
+func TestQueryBuilder_CountForPage(t *testing.T) {
+	conn := sqliteConnection(t).EnableQueryLog()
+	conn.FlushQueryLog()
+
+	database.NewBuilder(conn).From("users").Select("id", "name").Take(10).CountForPage("id")
+	logs := conn.GetQueryLog()
+	expect := `select count("id") as "aggregate" from (select "id" from "users" ) as "page_count" `
+	if len(logs) != 1 || logs[0].Sql != expect {
+		t.Fatalf("count for page got logs %v, expect %s", logs, expect)
+	}
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"math/rand"
+	"sync/atomic"
+)
+
+type writePoolKey struct{}
+
+type stickyKey struct{}
+
+// stickyState records writes of a sticky context
+type stickyState struct {
+	written int32
+}
+
+// UseWritePool returns context making selects of it use the write pool
+func UseWritePool(ctx context.Context) context.Context {
+	return context.WithValue(ctx, writePoolKey{}, true)
+}
+
+// StickyContext returns context recording writes of sticky connections,
+// selects with it use the write pool after a write, e.g. in the same request
+func StickyContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, stickyKey{}, &stickyState{})
+}
+
+// WithReadDsn makes selects use pools of dsn, one is picked randomly each query
+func WithReadDsn(dsn ...string) ConnectionOption {
+	return func(c *Connection) {
+		c.readDsns = dsn
+	}
+}
+
+// WithSticky makes selects use the write pool after a write of sticky context
+func WithSticky() ConnectionOption {
+	return func(c *Connection) {
+		c.sticky = true
+	}
+}
+
+func (c *Connection) GetReadDsns() []string {
+	return c.readDsns
+}
+
+// readExecutor returns executor for selects
+func (c *Connection) readExecutor(ctx context.Context) (executor, error) {
+	if c.tx != nil || len(c.readDsns) < 1 || ctx.Value(writePoolKey{}) != nil {
+		return c.executor()
+	}
+	if c.sticky {
+		if state, ok := ctx.Value(stickyKey{}).(*stickyState); ok && atomic.LoadInt32(&state.written) == 1 {
+			return c.executor()
+		}
+	}
+
+	return c.GetReadDB()
+}
+
+// recordWrite mark sticky context written
+func (c *Connection) recordWrite(ctx context.Context) {
+	if !c.sticky {
+		return
+	}
+	if state, ok := ctx.Value(stickyKey{}).(*stickyState); ok {
+		atomic.StoreInt32(&state.written, 1)
+	}
+}
+
+// GetReadDB returns a pool of read dsn picked randomly, or the write pool
+// if no read dsn given
+func (c *Connection) GetReadDB() (*sql.DB, error) {
+	if len(c.readDsns) < 1 {
+		return c.GetDB()
+	}
+
+	return c.openDB(c.readDsns[rand.Intn(len(c.readDsns))])
+}
@@ -0,0 +1,57 @@
+//go:build cgo
+// +build cgo
+
+package database_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/enorith/database"
+)
+
+func TestConnection_ReadWrite(t *testing.T) {
+	primary, replica := sqliteDsn(t), sqliteDsn(t)
+	for _, dsn := range []string{primary, replica} {
+		c := database.NewConnection("sqlite3", dsn)
+		createUsers(t, c)
+		c.Close()
+	}
+
+	conn := database.NewConnection("sqlite3", primary, database.WithReadDsn(replica), database.WithSticky())
+	defer conn.Close()
+	users := func(ctx context.Context) *database.QueryBuilder {
+		return database.NewBuilder(conn).WithContext(ctx).From("users")
+	}
+
+	if _, e := users(context.Background()).Insert([]map[string]interface{}{{"name": "tom"}}); e != nil {
+		t.Fatal(e)
+	}
+	if n, _ := users(context.Background()).CountOrError(); n != 0 {
+		t.Fatalf("read from replica got %d rows, expect 0", n)
+	}
+	if n, _ := users(context.Background()).LockForUpdate().CountOrError(); n != 1 {
+		t.Fatalf("locking read got %d rows, expect 1", n)
+	}
+
+	ctx := database.StickyContext(context.Background())
+	if n, _ := users(ctx).CountOrError(); n != 0 {
+		t.Fatalf("read before write got %d rows, expect 0", n)
+	}
+	if _, e := users(ctx).AndWhere("name", "=", "tom").Update(map[string]interface{}{"name": "tony"}); e != nil {
+		t.Fatal(e)
+	}
+	if n, _ := users(ctx).CountOrError(); n != 1 {
+		t.Fatalf("sticky read got %d rows, expect 1", n)
+	}
+
+	e := users(context.Background()).Transaction(func(builder *database.QueryBuilder) error {
+		if n, _ := builder.From("users").CountOrError(); n != 1 {
+			t.Fatalf("read in transaction got %d rows, expect 1", n)
+		}
+		return nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	ev "github.com/enorith/event"
+)
+
+// slowThreshold is the min threshold of slow query listeners, callers
+// of queries slower than it are captured
+var slowThreshold int64
+
+// ListenSlowQuery log queries not faster than threshold with raw sql, connection
+// name, duration and caller, logger is log.Printf if not given
+func ListenSlowQuery(threshold time.Duration, logger ...func(format string, v ...interface{})) {
+	if threshold <= 0 {
+		threshold = 1
+	}
+	logf := log.Printf
+	if len(logger) > 0 {
+		logf = logger[0]
+	}
+	for {
+		current := atomic.LoadInt64(&slowThreshold)
+		if current > 0 && current <= int64(threshold) {
+			break
+		}
+		if atomic.CompareAndSwapInt64(&slowThreshold, current, int64(threshold)) {
+			break
+		}
+	}
+
+	ev.BUS.Listen((&DBEvent{}).GetEventName(), func(e ev.Event, payload ...interface{}) {
+		de, ok := e.(*DBEvent)
+		if !ok || de.Duration < threshold {
+			return
+		}
+		logf("slow query [%s] %s (%s) at %s", de.Connection, de.GetRawSql(), de.Duration, de.Caller)
+	})
+}
+
+func isSlow(duration time.Duration) bool {
+	threshold := atomic.LoadInt64(&slowThreshold)
+
+	return threshold > 0 && int64(duration) >= threshold
+}
+
+// caller returns "file:line" of the first caller outside of the package
+func caller() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "github.com/enorith/database.") &&
+			!strings.HasPrefix(frame.Function, "github.com/enorith/database/") {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
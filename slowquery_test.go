@@ -0,0 +1,67 @@
+//go:build cgo
+// +build cgo
+
+package database_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/enorith/database"
+)
+
+func TestListenSlowQuery(t *testing.T) {
+	dsn := sqliteDsn(t)
+	m := database.NewManager()
+	m.Register("slow", func() (*database.Connection, error) {
+		return database.NewConnection("sqlite3", dsn), nil
+	})
+	conn, e := m.GetConnection("slow")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer conn.Close()
+	createUsers(t, conn)
+
+	var logs []string
+	database.ListenSlowQuery(0, func(format string, v ...interface{}) {
+		logs = append(logs, fmt.Sprintf(format, v...))
+	})
+
+	if _, e = conn.Exec("insert into users (name) values (?)", "tom"); e != nil {
+		t.Fatal(e)
+	}
+	if len(logs) != 1 {
+		t.Fatalf("got %d slow query logs, expect 1", len(logs))
+	}
+	log := logs[0]
+	for _, expect := range []string{"[slow]", "insert into users (name) values ('tom')", "slowquery_test.go:"} {
+		if !strings.Contains(log, expect) {
+			t.Errorf("slow query log %q should contain %q", log, expect)
+		}
+	}
+}
+
+func TestDBEvent_GetRawSql(t *testing.T) {
+	cases := map[string]database.DBEvent{
+		"select * from users where name = 'it''s' and id = 1": {
+			Sql:      "select * from users where name = ? and id = ?",
+			Bindings: []interface{}{"it's", 1},
+		},
+		`select * from "users" where "id" = 10 and "name" = 'tom'`: {
+			Sql:      `select * from "users" where "id" = $2 and "name" = $1`,
+			Bindings: []interface{}{"tom", 10},
+		},
+		"select * from [users] where [deleted_at] is null and [id] = 3": {
+			Sql:      "select * from [users] where [deleted_at] is null and [id] = @p1",
+			Bindings: []interface{}{int32(3)},
+		},
+	}
+
+	for expect, e := range cases {
+		if raw := e.GetRawSql(); raw != expect {
+			t.Errorf("got raw sql %q, expect %q", raw, expect)
+		}
+	}
+}
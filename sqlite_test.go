@@ -0,0 +1,39 @@
+//go:build cgo
+// +build cgo
+
+package database_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/enorith/database"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteDsn returns path of a sqlite database in temp dir of the test
+func sqliteDsn(t *testing.T) string {
+	database.WithSqlite()
+
+	return filepath.Join(t.TempDir(), "test.db")
+}
+
+// sqliteConnection returns connection of a temp sqlite database with users table,
+// it's closed once the test finished
+func sqliteConnection(t *testing.T, options ...database.ConnectionOption) *database.Connection {
+	t.Helper()
+	conn := database.NewConnection("sqlite3", sqliteDsn(t), options...)
+	t.Cleanup(func() {
+		conn.Close()
+	})
+	createUsers(t, conn)
+
+	return conn
+}
+
+func createUsers(t *testing.T, conn *database.Connection) {
+	t.Helper()
+	if _, e := conn.Exec("create table users (id integer primary key, name text)"); e != nil {
+		t.Fatal(e)
+	}
+}
@@ -0,0 +1,152 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+var ErrNotInTransaction = errors.New("connection is not in transaction")
+
+type executor interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+type transaction struct {
+	tx         *sql.Tx
+	savepoints int
+}
+
+// Begin a transaction, returns a connection bound to it.
+// Begin on a connection already in transaction creates a savepoint
+func (c *Connection) Begin() (*Connection, error) {
+	return c.BeginContext(context.Background())
+}
+
+// BeginContext begin transaction with ctx, the transaction will be
+// rolled back if ctx is done before committed
+func (c *Connection) BeginContext(ctx context.Context) (*Connection, error) {
+	txConn := c.Clone()
+
+	if c.tx != nil {
+		c.tx.savepoints++
+		txConn.savepoint = fmt.Sprintf("enorith_savepoint_%d", c.tx.savepoints)
+		if err := c.execSavepoint(ctx, "create", txConn.savepoint); err != nil {
+			return nil, err
+		}
+
+		return txConn, nil
+	}
+
+	result, err := c.intercept(ctx, QueryInfo{Type: "begin", Sql: "begin"}, func(ctx context.Context, info QueryInfo) (Result, error) {
+		db, err := c.GetDB()
+		if err != nil {
+			return Result{}, err
+		}
+		tx, err := db.BeginTx(ctx, nil)
+
+		return Result{Tx: tx}, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	txConn.tx = &transaction{tx: result.Tx}
+
+	return txConn, nil
+}
+
+// Commit the transaction, or release the savepoint of nested transaction
+func (c *Connection) Commit() error {
+	if c.tx == nil {
+		return ErrNotInTransaction
+	}
+	if c.savepoint != "" {
+		return c.execSavepoint(context.Background(), "release", c.savepoint)
+	}
+	_, err := c.intercept(context.Background(), QueryInfo{Type: "commit", Sql: "commit"}, func(ctx context.Context, info QueryInfo) (Result, error) {
+		return Result{}, c.tx.tx.Commit()
+	})
+
+	return err
+}
+
+// Rollback the transaction, or rollback to the savepoint of nested transaction
+func (c *Connection) Rollback() error {
+	if c.tx == nil {
+		return ErrNotInTransaction
+	}
+	if c.savepoint != "" {
+		return c.execSavepoint(context.Background(), "rollback", c.savepoint)
+	}
+	_, err := c.intercept(context.Background(), QueryInfo{Type: "rollback", Sql: "rollback"}, func(ctx context.Context, info QueryInfo) (Result, error) {
+		return Result{}, c.tx.tx.Rollback()
+	})
+
+	return err
+}
+
+type savepointCompiler interface {
+	compileSavepoint(action, name string) string
+}
+
+// execSavepoint execute savepoint statement of grammar, it's skipped if the action
+// is not supported by the database
+func (c *Connection) execSavepoint(ctx context.Context, action, name string) error {
+	grammar, _ := c.GetGrammar()
+	compiler, ok := grammar.(savepointCompiler)
+	if !ok {
+		compiler = &SqlGrammar{}
+	}
+	sql := compiler.compileSavepoint(action, name)
+	if sql == "" {
+		return nil
+	}
+	_, err := c.ExecContext(ctx, sql)
+
+	return err
+}
+
+func (c *Connection) InTransaction() bool {
+	return c.tx != nil
+}
+
+// Transaction call handler in transaction, commit if handler returns nil,
+// otherwise rollback. The panic of handler will be raised again after rollback
+func (c *Connection) Transaction(handler func(tx *Connection) error) error {
+	return c.TransactionContext(context.Background(), handler)
+}
+
+func (c *Connection) TransactionContext(ctx context.Context, handler func(tx *Connection) error) (err error) {
+	tx, err := c.BeginContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if x := recover(); x != nil {
+			tx.Rollback()
+			panic(x)
+		}
+	}()
+
+	if err = handler(tx); err != nil {
+		if re := tx.Rollback(); re != nil {
+			return fmt.Errorf("rollback error: %v, on: %w", re, err)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// TransactionCall call handler in transaction
+//
+// Deprecated: handler can not reach the transaction, queries of handler run outside of it.
+// Use Transaction instead
+func (c *Connection) TransactionCall(handler func() error) error {
+	return c.Transaction(func(tx *Connection) error {
+		return handler()
+	})
+}
@@ -0,0 +1,80 @@
+//go:build cgo
+// +build cgo
+
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/enorith/database"
+	"github.com/mattn/go-sqlite3"
+)
+
+var registerSavepointDriver sync.Once
+
+func TestConnection_Savepoint(t *testing.T) {
+	// sqlite connection compiling savepoints of sql server
+	registerSavepointDriver.Do(func() {
+		sql.Register("sqlite3_savepoint", &sqlite3.SQLiteDriver{})
+	})
+	database.RegisterGrammar("sqlite3_savepoint", database.NewSqlServerGrammar())
+	var statements []string
+	conn := database.NewConnection("sqlite3_savepoint", sqliteDsn(t))
+	defer conn.Close()
+	conn.Use(func(ctx context.Context, info database.QueryInfo, next database.InterceptHandler) (database.Result, error) {
+		if strings.Contains(info.Sql, "transaction") {
+			statements = append(statements, info.Sql)
+			return database.Result{}, nil
+		}
+		return next(ctx, info)
+	})
+
+	e := conn.Transaction(func(tx *database.Connection) error {
+		tx.Transaction(func(nested *database.Connection) error {
+			return nil
+		})
+		nested, err := tx.Begin()
+		if err != nil {
+			return err
+		}
+		return nested.Rollback()
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	expect := []string{
+		"save transaction [enorith_savepoint_1]",
+		"save transaction [enorith_savepoint_2]",
+		"rollback transaction [enorith_savepoint_2]",
+	}
+	if strings.Join(statements, "; ") != strings.Join(expect, "; ") {
+		t.Fatalf("savepoint statements got %v, expect %v", statements, expect)
+	}
+}
+
+func TestConnection_NestedTransaction(t *testing.T) {
+	conn := sqliteConnection(t)
+	e := conn.Transaction(func(tx *database.Connection) error {
+		if _, err := tx.Exec("insert into users (name) values (?)", "tom"); err != nil {
+			return err
+		}
+		nested, err := tx.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err = nested.Exec("insert into users (name) values (?)", "bob"); err != nil {
+			return err
+		}
+		return nested.Rollback()
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if n, _ := database.NewBuilder(conn).From("users").CountOrError(); n != 1 {
+		t.Fatalf("got %d users after rolling back savepoint, expect 1", n)
+	}
+}